@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"yourmodule/internal/config"
+	"yourmodule/internal/export"
+	"yourmodule/internal/logging"
+	"yourmodule/internal/models"
+)
+
+// runExport подкоманда export: выгрузка заказов под фильтр в файл или stdout.
+//
+//	order-service export -from 2026-01-01 -to 2026-02-01 -out orders-2026-01.xlsx
+func runExport(args []string) (err error) {
+	fs := flag.NewFlagSet("order-service export", flag.ContinueOnError)
+	formatFlag := fs.String("format", "", "ndjson, csv or xlsx (default: by -out extension, else ndjson)")
+	out := fs.String("out", "-", "output file, - for stdout")
+	from := fs.String("from", "", "date_created lower bound, inclusive: 2006-01-02 or RFC 3339")
+	to := fs.String("to", "", "date_created upper bound, exclusive: 2006-01-02 or RFC 3339")
+	deliveryService := fs.String("delivery-service", "", "only orders of this delivery service")
+	customerID := fs.String("customer-id", "", "only orders of this customer")
+
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	// stdout может быть занят выгрузкой, поэтому логи в stderr
+	logger, err := logging.New(os.Stderr, cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+	if *formatFlag == "" {
+		if f, ok := export.FormatFromPath(*out); ok {
+			format = f
+		}
+	}
+	filter := models.OrderFilter{DeliveryService: *deliveryService, CustomerID: *customerID}
+	if *from != "" {
+		if filter.From, err = export.ParseTime(*from); err != nil {
+			return fmt.Errorf("-from: %w", err)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = export.ParseTime(*to); err != nil {
+			return fmt.Errorf("-to: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := connectDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, cerr := os.Create(*out)
+		if cerr != nil {
+			return cerr
+		}
+		w = f
+		defer func() {
+			// недописанный файл хуже отсутствующего
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(*out)
+			}
+		}()
+	}
+
+	var n int
+	n, err = exportOrders(ctx, store, filter, format, w)
+	if err != nil {
+		return err
+	}
+	slog.Info("export finished", "orders", n, "format", format, "out", *out)
+	return nil
+}
+
+// orderStreamer источник заказов для выгрузки
+type orderStreamer interface {
+	StreamOrders(ctx context.Context, f models.OrderFilter, fn func(models.StoredOrder, error) error) error
+}
+
+// exportOrders пишет заказы под фильтр в w и возвращает их число
+func exportOrders(ctx context.Context, store orderStreamer, f models.OrderFilter, format export.Format, w io.Writer) (int, error) {
+	ew, err := export.NewWriter(format, w)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	err = store.StreamOrders(ctx, f, func(so models.StoredOrder, rowErr error) error {
+		if rowErr != nil {
+			slog.WarnContext(ctx, "corrupt order skipped", "order_uid", so.Order.OrderUID, "err", rowErr)
+			return nil
+		}
+		n++
+		return ew.Write(so)
+	})
+	if cerr := ew.Close(); err == nil {
+		err = cerr
+	}
+	if errors.Is(err, context.Canceled) {
+		err = errors.New("export interrupted")
+	}
+	return n, err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"yourmodule/internal/config"
+	"yourmodule/internal/envelope"
+	"yourmodule/internal/importer"
+	"yourmodule/internal/logging"
+)
+
+// runImport подкоманда import: заказы из NDJSON или JSON-массива (можно .gz)
+// проходят валидацию и SaveOrder, итог печатается в stdout.
+//
+//	order-service import -workers 8 -report report.json orders-2020.ndjson.gz
+func runImport(args []string) (err error) {
+	fs := flag.NewFlagSet("order-service import", flag.ContinueOnError)
+	workers := fs.Int("workers", 4, "orders saved concurrently")
+	dryRun := fs.Bool("dry-run", false, "only validate, save nothing")
+	strict := fs.Bool("strict", false, "reject orders with unknown fields (also kafka.strict-fields)")
+	reportPath := fs.String("report", "", "also write the report as JSON to this file")
+
+	cfg, err := config.LoadFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		return errors.New("no input files, use - for stdin")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	opts := []importer.Option{
+		importer.WithWorkers(*workers),
+		importer.WithDryRun(*dryRun),
+		importer.WithStrictFields(*strict || cfg.Kafka.StrictFields),
+		// те же версии схемы, что принимает consumer
+		importer.WithSchemaRegistry(envelope.NewOrderRegistry()),
+	}
+	// для dry run БД не нужна
+	var store importer.Store
+	if !*dryRun {
+		// реплики с кэшем процесса узнают о перезаписанных заказах;
+		// для заливки только новых заказов уведомления можно выключить CACHE_INVALIDATION=false
+		s, err := connectDB(ctx, cfg.DB, storeOptions(cfg.Cache, replicaID())...)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		store = s
+	}
+	im := importer.New(store, opts...)
+
+	var total importer.Report
+	for _, name := range files {
+		rep, err := importFile(ctx, im, name)
+		total.Add(rep)
+		slog.Info("file imported", "file", name, "records", rep.Records, "accepted", rep.Accepted, "rejected", rep.Rejected)
+		if err != nil {
+			// итог по уже обработанному всё равно нужен
+			_ = total.WriteText(os.Stdout)
+			return err
+		}
+	}
+
+	if *reportPath != "" {
+		data, err := json.MarshalIndent(total, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*reportPath, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return total.WriteText(os.Stdout)
+}
+
+func importFile(ctx context.Context, im *importer.Importer, name string) (importer.Report, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return importer.Report{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return im.Import(ctx, name, r)
+}
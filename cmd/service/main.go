@@ -1,116 +1,344 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"yourmodule/internal/api"
+	"yourmodule/internal/auth"
 	"yourmodule/internal/cache"
+	"yourmodule/internal/codec"
+	"yourmodule/internal/config"
 	"yourmodule/internal/consumer"
 	"yourmodule/internal/db"
+	"yourmodule/internal/envelope"
+	"yourmodule/internal/invalidation"
+	"yourmodule/internal/logging"
+	"yourmodule/internal/models"
+	"yourmodule/internal/outbox"
+	"yourmodule/internal/ratelimit"
+	"yourmodule/internal/stream"
+	"yourmodule/internal/tracing"
+	"yourmodule/internal/warmup"
+	"yourmodule/internal/webhook"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
 )
 
+// commands разовые подкоманды бинарника
+var commands = map[string]func(args []string) error{
+	"export": runExport,
+	"import": runImport,
+}
+
 func main() {
+	// разовые подкоманды: отрабатывают и завершаются, роль им не нужна
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fatal(os.Args[1]+" failed", err)
+			}
+			return
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Конфиг
-	pgDSN := envOr("PG_DSN", "postgres://"+os.Getenv("DB_USER")+":"+os.Getenv("DB_PASSWORD")+"@"+os.Getenv("DB_HOST")+":"+os.Getenv("DB_PORT")+"/"+os.Getenv("DB_NAME")+"?sslmode=disable")
-	kafkaBroker := envOr("KAFKA_BROKERS", "kafka:9092")
-	kafkaTopic := envOr("KAFKA_TOPIC", "orders")
-	kafkaGroup := envOr("KAFKA_GROUP", "order-service-group")
-	httpAddr := envOr("HTTP_PORT", ":8082")
-
-	log.Printf("Config: PG_DSN=%s", pgDSN)
-	log.Printf("Config: Kafka=%s Topic=%s Group=%s", kafkaBroker, kafkaTopic, kafkaGroup)
-	log.Printf("Config: HTTP=%s", httpAddr)
-
-	// Подключение к БД
-	var store *db.Store
-	var err error
-	for i := 0; i < 20; i++ {
-		store, err = db.NewStore(ctx, pgDSN)
-		if err == nil {
-			break
-		}
-		log.Printf("DB connect failed (try %d/20): %v", i+1, err)
-		time.Sleep(2 * time.Second)
+	cfg, err := config.Load(roleArgs(os.Args[1:]))
+	if err != nil {
+		fatal("config load failed", err)
+	}
+
+	// Логгер
+	logger, err := logging.New(os.Stdout, cfg.LogLevel)
+	if err != nil {
+		fatal("logger init failed", err)
 	}
+	slog.SetDefault(logger)
+	slog.Info("config loaded", "role", cfg.Role, "config", cfg)
+
+	// Трейсинг
+	shutdownTracing, err := tracing.Init(ctx, tracing.Config{
+		ServiceName: "order-service",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+	})
 	if err != nil {
-		log.Fatalf("DB connect failed: %v", err)
+		fatal("tracing init failed", err)
+	}
+
+	// Подключение к БД; replica подписывает уведомления о сохранённых заказах
+	replica := replicaID()
+	store, err := connectDB(ctx, cfg.DB, storeOptions(cfg.Cache, replica)...)
+	if err != nil {
+		fatal("DB connect failed", err)
 	}
 	defer store.Close()
 
-	// Кэш
-	cacheTTL := 5 * time.Minute
-	cleanupInterval := 1 * time.Minute
-	c := cache.New(cacheTTL, cleanupInterval)
+	// Кэш; счётчики обращений нужны, только если их сохранять для прогрева
+	cacheOpts := []cache.Option{cache.WithShards(cfg.Cache.Shards)}
+	if cfg.Warmup.AccessFile != "" {
+		cacheOpts = append(cacheOpts, cache.WithAccessTracking(accessTracked(cfg.Warmup)))
+	}
+	// версия — created_at в БД: прочитанный до сохранения заказ не затрёт более новый
+	c := cache.NewVersioned[string](cfg.Cache.TTL, cfg.Cache.CleanupInterval, orderVersion, cacheOpts...)
+	// orders то, с чем работают API, consumer и прогрев: c, Redis или оба уровня
+	orders, closeCache := newOrderCache(ctx, cfg.Cache, c)
+	defer closeCache()
 
-	// Прогрев кэша
-	go warmCache(ctx, store, c, cacheTTL)
+	// Заказы, сохранённые другими репликами, убираются из кэша процесса
+	if cfg.Role.ServesHTTP() && invalidates(cfg.Cache) {
+		go invalidation.NewListener(store, c, invalidation.WithReplica(replica)).Run(ctx)
+	}
+
+	// Прогрев кэша нужен только там, где есть HTTP: сначала снапшот прошлого
+	// запуска, затем БД освежает и дополняет его
+	var warmer *warmup.Warmer
+	if cfg.Role.ServesHTTP() {
+		if cfg.Cache.SnapshotFile != "" {
+			restoreCache(c, cfg.Cache.SnapshotFile)
+		}
+		warmer = warmup.New(store, orders, warmup.Config{
+			Strategy:   warmup.Strategy(cfg.Warmup.Strategy),
+			Limit:      cfg.Warmup.Limit,
+			Window:     cfg.Warmup.Window,
+			AccessFile: cfg.Warmup.AccessFile,
+			ExportFile: cfg.Warmup.ExportFile,
+			Timeout:    cfg.Warmup.Timeout,
+		})
+		go warmer.Run(ctx)
+	}
+
+	// Поток новых заказов: работает, только если consumer и HTTP в одном процессе
+	var hub *stream.Hub
+	if cfg.Role.ServesHTTP() && cfg.Role.ConsumesKafka() {
+		hub = stream.NewHub(cfg.Stream.Buffer, cfg.Stream.MaxClients)
+	}
 
 	// Kafka consumer через обёртку kafkaReaderWrapper
-	go runConsumerWithRetry(ctx, func() *consumer.Consumer {
-		reader := &consumer.KafkaReaderWrapper{
-			R: kafka.NewReader(kafka.ReaderConfig{
-				Brokers: []string{kafkaBroker},
-				Topic:   kafkaTopic,
-				GroupID: kafkaGroup,
-			}),
+	if cfg.Role.ConsumesKafka() {
+		// декодеры общие для перезапусков: в Avro кэшируются схемы
+		decoders := codec.Default(codec.NewFileRegistry(cfg.Kafka.AvroSchemaDir))
+		// версии схемы те же, что принимает import
+		schemas := envelope.NewOrderRegistry()
+
+		// webhook'и партнёров: события уходят после сохранения заказа
+		dispatcher := webhook.NewDispatcher(store,
+			webhook.WithWorkers(cfg.Webhooks.Workers),
+			webhook.WithRetry(cfg.Webhooks.MaxAttempts, cfg.Webhooks.BackoffMin, cfg.Webhooks.BackoffMax),
+			webhook.WithClient(&http.Client{Timeout: cfg.Webhooks.Timeout}),
+			webhook.WithRefresh(cfg.Webhooks.Refresh),
+			webhook.WithPoll(cfg.Webhooks.Poll),
+		)
+		go dispatcher.Run(ctx)
+
+		go runConsumerWithRetry(ctx, cfg.Kafka, func() *consumer.Consumer {
+			reader := &consumer.KafkaReaderWrapper{
+				R: kafka.NewReader(kafka.ReaderConfig{
+					Brokers: cfg.Kafka.Brokers,
+					Topic:   cfg.Kafka.Topic,
+					GroupID: cfg.Kafka.Group,
+				}),
+			}
+			opts := []consumer.Option{
+				consumer.WithStrictFields(cfg.Kafka.StrictFields),
+				consumer.WithDecoders(decoders),
+				consumer.WithSchemaRegistry(schemas),
+				consumer.WithNotifier(dispatcher),
+			}
+			if hub != nil {
+				opts = append(opts, consumer.WithNotifier(hub))
+			}
+			return consumer.New(reader, store, orders, opts...)
+		})
+	}
+
+	// Outbox relay: события заказов из order_events в Kafka
+	if cfg.Role.RunsBackground() {
+		writer := &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Kafka.Brokers...),
+			Topic:                  cfg.Outbox.Topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
 		}
-		return consumer.New(reader, store, c)
-	})
+		defer writer.Close()
+		relay := outbox.NewRelay(store, writer,
+			outbox.WithBatchSize(cfg.Outbox.BatchSize),
+			outbox.WithInterval(cfg.Outbox.Interval),
+			outbox.WithRetention(cfg.Outbox.Retention),
+		)
+		go relay.Run(ctx)
+	}
 
 	// HTTP сервер
-	srv := api.NewServer(store, c)
-	httpSrv := &http.Server{
-		Addr:         httpAddr,
-		Handler:      srv.Routes(),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	go func() {
-		log.Printf("HTTP listening on %s", httpAddr)
-		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+	var httpSrv *http.Server
+	if cfg.Role.ServesHTTP() {
+		var opts []api.Option
+		if cfg.Auth.Enabled() {
+			authenticator, err := newAuthenticator(cfg.Auth)
+			if err != nil {
+				fatal("auth init failed", err)
+			}
+			opts = append(opts, api.WithAuth(authenticator))
+		} else {
+			slog.Warn("HTTP API authentication disabled")
+		}
+
+		if cfg.RateLimit.Enabled() {
+			def, routes, err := cfg.RateLimit.Rules()
+			if err != nil {
+				fatal("rate limit config", err)
+			}
+			opts = append(opts, api.WithRateLimit(ratelimit.NewPerRoute(def, routes), cfg.RateLimit.TrustProxy))
 		}
-	}()
+
+		opts = append(opts,
+			api.WithWebhooks(store),
+			api.WithBatchLimit(cfg.HTTP.BatchLimit),
+			api.WithReadiness(warmer.Ready),
+		)
+		if hub != nil {
+			opts = append(opts, api.WithStream(hub))
+		}
+		srv := api.NewServer(store, orders, opts...)
+		httpSrv = &http.Server{
+			Addr:         cfg.HTTP.Addr,
+			Handler:      srv.Routes(),
+			ReadTimeout:  cfg.HTTP.ReadTimeout,
+			WriteTimeout: cfg.HTTP.WriteTimeout,
+			IdleTimeout:  cfg.HTTP.IdleTimeout,
+		}
+
+		go func() {
+			slog.Info("HTTP listening", "addr", cfg.HTTP.Addr)
+			if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("HTTP server error", err)
+			}
+		}()
+	}
 
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
-	log.Println("shutting down...")
+	slog.Info("shutting down")
 
 	cancel()
-	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 	defer cancelShutdown()
-	_ = httpSrv.Shutdown(ctxShutdown)
+	if httpSrv != nil {
+		_ = httpSrv.Shutdown(ctxShutdown)
+	}
+	// кэш и счётчики сохраняются после остановки HTTP, когда обращений больше не будет
+	if cfg.Role.ServesHTTP() && cfg.Warmup.AccessFile != "" {
+		if err := warmup.SaveAccess(cfg.Warmup.AccessFile, c.TopAccessed(accessTracked(cfg.Warmup))); err != nil {
+			slog.Warn("cache access stats not saved", "file", cfg.Warmup.AccessFile, "err", err)
+		}
+	}
+	if cfg.Role.ServesHTTP() && cfg.Cache.SnapshotFile != "" {
+		saveCache(c, cfg.Cache.SnapshotFile)
+	}
+	_ = shutdownTracing(ctxShutdown)
+
+	slog.Info("done")
+}
+
+// roleArgs превращает подкоманду (order-service api ...) во флаг -role,
+// чтобы роль можно было задать и так, и через -role / SERVICE_ROLE
+func roleArgs(args []string) []string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	return append([]string{"-role", args[0]}, args[1:]...)
+}
+
+// connectDB подключается к Postgres с повторами
+func connectDB(ctx context.Context, cfg config.DBConfig, opts ...db.Option) (store *db.Store, err error) {
+	for i := 0; i < cfg.ConnectAttempts; i++ {
+		store, err = db.NewStore(ctx, cfg.PostgresDSN(), opts...)
+		if err == nil {
+			return store, nil
+		}
+		slog.Warn("DB connect failed", "attempt", i+1, "max_attempts", cfg.ConnectAttempts, "err", err)
+		time.Sleep(cfg.ConnectBackoff)
+	}
+	return nil, err
+}
 
-	log.Println("done")
+// invalidates нужен ли сброс кэша процесса между репликами. С backend redis
+// кэша в процессе нет: новую версию в Redis кладёт consumer, а устаревшее чтение
+// из БД её не затрёт, потому что Redis сравнивает версии при записи.
+func invalidates(cfg config.CacheConfig) bool {
+	return cfg.Invalidation && cfg.Backend != "redis"
 }
 
-// envOr возвращает переменную окружения или дефолт
-func envOr(key, def string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		return def
+// storeOptions опции Store: уведомления о сохранённых заказах рассылаются,
+// только если реплики их слушают
+func storeOptions(cfg config.CacheConfig, replica string) []db.Option {
+	if !invalidates(cfg) {
+		return nil
+	}
+	return []db.Option{db.WithInvalidation(replica)}
+}
+
+// replicaID уникальный id процесса: имя хоста и случайный суффикс
+func replicaID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// newAuthenticator собирает аутентификатор API из конфига
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	ac := auth.Config{
+		HS256Secret: []byte(cfg.JWTSecret),
+		Issuer:      cfg.JWTIssuer,
+		Audience:    cfg.JWTAudience,
+	}
+	for _, raw := range cfg.APIKeys {
+		k, err := auth.ParseAPIKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		ac.APIKeys = append(ac.APIKeys, k)
+	}
+	if cfg.JWTPublicKeyFile != "" {
+		pub, err := auth.LoadRSAPublicKey(cfg.JWTPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWT public key: %w", err)
+		}
+		ac.RS256PublicKey = pub
 	}
-	return v
+	return auth.New(ac)
+}
+
+// fatal логирует ошибку и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
 
 // runConsumerWithRetry запускает consumer с бэкоффом при падении
-func runConsumerWithRetry(ctx context.Context, create func() *consumer.Consumer) {
-	backoff := 500 * time.Millisecond
+func runConsumerWithRetry(ctx context.Context, cfg config.KafkaConfig, create func() *consumer.Consumer) {
+	backoff := cfg.RetryBackoffMin
 	for {
 		select {
 		case <-ctx.Done():
@@ -119,48 +347,110 @@ func runConsumerWithRetry(ctx context.Context, create func() *consumer.Consumer)
 		}
 
 		cons := create()
-		log.Println("starting kafka consumer")
+		slog.Info("starting kafka consumer")
 		cons.Run(ctx)
 		_ = cons.Close()
 
-		log.Println("consumer stopped, retrying...")
+		slog.Warn("consumer stopped, retrying", "backoff", backoff)
 		time.Sleep(backoff)
-		if backoff < 10*time.Second {
-			backoff *= 2
+		if backoff < cfg.RetryBackoffMax {
+			backoff = min(backoff*2, cfg.RetryBackoffMax)
 		}
 	}
 }
 
-// warmCache прогревает кэш
-func warmCache(ctx context.Context, store *db.Store, c *cache.Cache, cacheTTL time.Duration) {
-	ctxWarm, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+// orderCache кэш заказов по order_uid
+type orderCache interface {
+	Get(key string) (models.StoredOrder, bool)
+	Set(key string, so models.StoredOrder, ttl time.Duration)
+}
 
-	log.Println("starting cache warmup...")
+// newOrderCache выбирает кэш заказов по cache.backend. Недоступный при старте
+// Redis не мешает запуску: кэш работает как промах, пока Redis не появится.
+func newOrderCache(ctx context.Context, cfg config.CacheConfig, local *cache.Cache[string, models.StoredOrder]) (orderCache, func()) {
+	if cfg.Backend == "memory" {
+		return local, func() {}
+	}
 
-	backoff := 300 * time.Millisecond
-	attempt := 1
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	ctxPing, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctxPing).Err(); err != nil {
+		slog.Warn("redis not reachable on startup", "addr", cfg.Redis.Addr, "err", err)
+	}
+	// версии сравниваются и в Redis: чтение из БД в API не затрёт заказ, который
+	// consumer сохранил позже
+	remote := cache.NewVersionedRedis(client, cfg.TTL, orderVersion,
+		cache.WithKeyPrefix(cfg.Redis.KeyPrefix),
+		cache.WithOpTimeout(cfg.Redis.Timeout),
+	)
+	closeClient := func() { _ = client.Close() }
+	if cfg.Backend == "tiered" {
+		return cache.NewTiered(local, remote, cfg.LocalTTL), closeClient
+	}
+	return remote, closeClient
+}
 
-	for {
-		loaded, err := store.LoadAllOrders(ctxWarm, 1000)
-		if err == nil {
-			for k, v := range loaded {
-				c.Set(k, v, cacheTTL)
-			}
-			log.Printf("cache warmed with %d orders", len(loaded))
-			return
-		}
+// restoreCache поднимает кэш из снапшота прошлого запуска. Без снапшота или
+// с битым кэш просто остаётся холодным.
+func restoreCache(c *cache.Cache[string, models.StoredOrder], path string) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("no cache snapshot, starting cold", "file", path)
+		return
+	}
+	if err != nil {
+		slog.Warn("cache snapshot not loaded", "file", path, "err", err)
+		return
+	}
+	defer f.Close()
 
-		if ctxWarm.Err() != nil {
-			log.Printf("warn: cache warmup stopped: %v", ctxWarm.Err())
-			return
-		}
+	start := time.Now()
+	n, err := c.LoadSnapshot(bufio.NewReader(f))
+	if err != nil {
+		slog.Warn("cache snapshot partially loaded", "file", path, "orders", n, "err", err)
+		return
+	}
+	slog.Info("cache restored from snapshot", "file", path, "orders", n, "duration", time.Since(start))
+}
 
-		log.Printf("warn: warmup failed (attempt %d): %v", attempt, err)
-		attempt++
-		time.Sleep(backoff)
-		if backoff < 3*time.Second {
-			backoff *= 2
-		}
+// saveCache пишет снапшот кэша через временный файл и rename,
+// чтобы прерванная запись не испортила предыдущий снапшот
+func saveCache(c *cache.Cache[string, models.StoredOrder], path string) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		slog.Warn("cache snapshot not saved", "file", path, "err", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	bw := bufio.NewWriter(tmp)
+	n, err := c.SaveSnapshot(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
 	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		slog.Warn("cache snapshot not saved", "file", path, "err", err)
+		return
+	}
+	slog.Info("cache snapshot saved", "file", path, "orders", n)
 }
+
+// accessTracked сколько ключей считать для прогрева popular: с запасом к warmup.limit,
+// чтобы заказы на границе топа не вытеснялись случайными одиночными обращениями
+func accessTracked(cfg config.WarmupConfig) int {
+	return max(cfg.Limit*4, 10000)
+}
+
+// orderVersion версия заказа в кэше: created_at в БД растёт с каждым сохранением
+func orderVersion(so models.StoredOrder) int64 { return so.CreatedAt.UnixNano() }
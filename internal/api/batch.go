@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultBatchLimit сколько заказов можно запросить одним batchGet
+const DefaultBatchLimit = 500
+
+// batchChunk сколько uid batchGet стоят один токен лимита: запрос на 500 заказов
+// не должен стоить столько же, сколько на один
+const batchChunk = 50
+
+type batchGetRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+type batchGetResponse struct {
+	Orders  []any    `json:"orders"`
+	Missing []string `json:"missing"`
+	// Corrupt заказы есть в БД, но прочитать их нельзя
+	Corrupt []string `json:"corrupt"`
+}
+
+// BatchGetOrders POST /orders:batchGet — несколько заказов за один запрос.
+// Ищет каждый uid в кэше, промахи достаёт из БД одним запросом.
+// Порядок заказов в ответе совпадает с порядком в запросе, дубликаты схлопываются.
+func (s *Server) BatchGetOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "api.BatchGetOrders",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.route", "/orders:batchGet")),
+	)
+	defer span.End()
+
+	rawFields := r.URL.Query().Get("fields")
+	fields, err := parseFields(rawFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req batchGetRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(s.batchLimit)*256+1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	uids := dedupe(req.OrderUIDs)
+	if len(uids) == 0 {
+		http.Error(w, "order_uids required", http.StatusBadRequest)
+		return
+	}
+	if len(uids) > s.batchLimit {
+		http.Error(w, fmt.Sprintf("at most %d order_uids per request", s.batchLimit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// первый токен списал rateLimit
+	if !s.charge(w, r, (len(uids)-1)/batchChunk) {
+		return
+	}
+	span.SetAttributes(attribute.Int("orders.requested", len(uids)))
+
+	// 1) кэш
+	found := make(map[string]models.StoredOrder, len(uids))
+	var misses []string
+	for _, id := range uids {
+		if so, ok := s.cache.Get(id); ok {
+			found[id] = so
+			continue
+		}
+		misses = append(misses, id)
+	}
+	span.SetAttributes(attribute.Int("cache.hits", len(found)))
+
+	// 2) db, одним запросом на все промахи
+	corrupt := map[string]bool{}
+	if len(misses) > 0 {
+		fromDB, broken, err := s.store.GetOrders(ctx, misses)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "db lookup failed")
+			slog.ErrorContext(ctx, "batch order lookup failed", "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		for id, so := range fromDB {
+			found[id] = so
+			s.cache.Set(id, so, 0)
+		}
+		for _, id := range broken {
+			corrupt[id] = true
+		}
+	}
+
+	p, _ := auth.FromContext(r.Context())
+	showPII := s.auth == nil || privileged(p)
+
+	resp := batchGetResponse{Orders: make([]any, 0, len(found)), Missing: []string{}, Corrupt: []string{}}
+	for _, id := range uids {
+		so, ok := found[id]
+		if corrupt[id] {
+			resp.Corrupt = append(resp.Corrupt, id)
+			continue
+		}
+		if !ok {
+			resp.Missing = append(resp.Missing, id)
+			continue
+		}
+		// как и GET /order/{id}: без маскирования и проекции — сохранённый payload
+		if showPII && fields == nil && len(so.Payload) > 0 {
+			resp.Orders = append(resp.Orders, json.RawMessage(so.Payload))
+			continue
+		}
+		body, err := shapeOrder(so.Order, showPII, fields)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Orders = append(resp.Orders, body)
+	}
+	span.SetAttributes(attribute.Int("orders.missing", len(resp.Missing)), attribute.Int("orders.corrupt", len(resp.Corrupt)))
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dedupe убирает пустые и повторяющиеся uid, сохраняя порядок
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
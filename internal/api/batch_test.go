@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+	"yourmodule/internal/ratelimit"
+)
+
+func postBatch(server *Server, url, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	return w
+}
+
+type batchResult struct {
+	Orders  []models.Order `json:"orders"`
+	Missing []string       `json:"missing"`
+	Corrupt []string       `json:"corrupt"`
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	store := &fakeStore{}
+	cache := newFakeCache()
+	cache.Set("555", newFullOrder(), time.Minute)
+	server := NewServer(store, cache)
+
+	w := postBatch(server, "/orders:batchGet", `{"order_uids":["555","123","nope","555"]}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got batchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Orders) != 2 || got.Orders[0].OrderUID != "555" || got.Orders[1].OrderUID != "123" {
+		t.Fatalf("expected orders in request order, got %+v", got.Orders)
+	}
+	if len(got.Missing) != 1 || got.Missing[0] != "nope" {
+		t.Fatalf("unexpected missing: %v", got.Missing)
+	}
+
+	// в БД уходят только промахи кэша и одним запросом
+	if len(store.batchCalls) != 1 || strings.Join(store.batchCalls[0], ",") != "123,nope" {
+		t.Fatalf("expected one DB query for misses, got %v", store.batchCalls)
+	}
+	if _, ok := cache.Get("123"); !ok {
+		t.Fatalf("orders from DB must be cached")
+	}
+
+	// второй запрос целиком из кэша
+	postBatch(server, "/orders:batchGet", `{"order_uids":["555","123"]}`, "")
+	if len(store.batchCalls) != 1 {
+		t.Fatalf("cached orders must not hit DB, got %v", store.batchCalls)
+	}
+}
+
+func TestBatchGetOrders_CorruptNotMissing(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache())
+
+	w := postBatch(server, "/orders:batchGet", `{"order_uids":["123","broken","nope"]}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got batchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Orders) != 1 || len(got.Missing) != 1 || got.Missing[0] != "nope" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if len(got.Corrupt) != 1 || got.Corrupt[0] != "broken" {
+		t.Fatalf("unreadable order must be reported as corrupt, got %v", got.Corrupt)
+	}
+}
+
+func TestBatchGetOrders_Limits(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache(), WithBatchLimit(2))
+
+	if w := postBatch(server, "/orders:batchGet", `{"order_uids":["1","2","3"]}`, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 over the limit, got %d", w.Code)
+	}
+	// дубликаты не считаются
+	if w := postBatch(server, "/orders:batchGet", `{"order_uids":["1","2","2","1"]}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for duplicates within the limit, got %d", w.Code)
+	}
+	for _, body := range []string{`{"order_uids":[]}`, `{}`, `{"ids":["1"]}`, `[`} {
+		if w := postBatch(server, "/orders:batchGet", body, ""); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestBatchGetOrders_ChargedPerChunk(t *testing.T) {
+	limits := ratelimit.NewPerRoute(ratelimit.Rule{RPS: 0.001, Burst: 10}, nil)
+	server := NewServer(&fakeStore{}, newFakeCache(), WithRateLimit(limits, false))
+
+	uids := make([]string, 5*batchChunk)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("uid-%d", i)
+	}
+	body, _ := json.Marshal(batchGetRequest{OrderUIDs: uids})
+
+	// 5 пачек — 5 токенов из 10
+	for i := 0; i < 2; i++ {
+		if w := postBatch(server, "/orders:batchGet", string(body), ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	if w := postBatch(server, "/orders:batchGet", `{"order_uids":["a"]}`, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after 10 chunks, got %d", w.Code)
+	}
+}
+
+func TestBatchGetOrders_MaskedForPartner(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Key: "partner", Subject: "p", Role: auth.RolePartner}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newFakeCache()
+	cache.Set("555", newFullOrder(), time.Minute)
+	server := NewServer(&fakeStore{}, cache, WithAuth(a))
+
+	w := postBatch(server, "/orders:batchGet?fields=order_uid,delivery.email", `{"order_uids":["555"]}`, "partner")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "test@example.com") || !strings.Contains(w.Body.String(), "t***@example.com") {
+		t.Fatalf("partner must get masked email: %s", w.Body)
+	}
+	if strings.Contains(w.Body.String(), "track_number") {
+		t.Fatalf("fields projection must be applied: %s", w.Body)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// orderETag строгий ETag по сохранённому payload и варианту ответа
+func orderETag(payload []byte, showPII bool, fields string) string {
+	h := sha256.New()
+	h.Write(payload)
+	if showPII {
+		h.Write([]byte{0, 1})
+	} else {
+		h.Write([]byte{0, 0})
+	}
+	h.Write([]byte(fields))
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// notModified проверяет If-None-Match, а при его отсутствии If-Modified-Since
+// (RFC 9110, 13.2.2)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// Last-Modified передаётся с точностью до секунды
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatch слабое сравнение со списком из If-None-Match
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
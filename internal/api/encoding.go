@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cachepkg "yourmodule/internal/cache"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+
+	// payload меньше этого размера не сжимаем — выигрыш съедают заголовки
+	minCompressSize = 512
+	// сколько держим сжатые варианты после последнего обращения
+	variantsTTL = 5 * time.Minute
+)
+
+// variants сжатые копии одного payload
+type variants struct {
+	once sync.Once
+	br   []byte
+	gzip []byte
+}
+
+// compress считает оба варианта один раз
+func (v *variants) compress(payload []byte) {
+	v.once.Do(func() {
+		var buf bytes.Buffer
+		bw := brotli.NewWriterLevel(&buf, 9)
+		if _, err := bw.Write(payload); err == nil && bw.Close() == nil {
+			v.br = bytes.Clone(buf.Bytes())
+		}
+
+		buf.Reset()
+		gw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := gw.Write(payload); err == nil && gw.Close() == nil {
+			v.gzip = bytes.Clone(buf.Bytes())
+		}
+	})
+}
+
+// variantStore сжатые варианты по ETag. ETag зависит только от содержимого,
+// поэтому запись не бывает устаревшей — TTL лишь ограничивает память.
+type variantStore struct {
+	cache *cachepkg.Cache[string, *variants]
+	mu    sync.Mutex
+}
+
+func (s *variantStore) get(etag string, payload []byte) *variants {
+	s.mu.Lock()
+	v, ok := s.cache.Get(etag)
+	if !ok {
+		v = &variants{}
+	}
+	// продлеваем TTL при каждом обращении
+	s.cache.Set(etag, v, variantsTTL)
+	s.mu.Unlock()
+
+	v.compress(payload)
+	return v
+}
+
+// negotiateEncoding выбирает br или gzip по Accept-Encoding; "" — без сжатия
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encodingBrotli && name != encodingGzip {
+			continue
+		}
+		// при равном q предпочитаем brotli
+		if q > bestQ || (q == bestQ && q > 0 && name == encodingBrotli) {
+			best, bestQ = name, q
+		}
+	}
+	if bestQ <= 0 {
+		return ""
+	}
+	return best
+}
+
+// encodedETag ETag сжатого представления отличается от исходного
+func encodedETag(etag, enc string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + enc + `"`
+}
+
+// writeRaw отдаёт сохранённый payload как есть, сжатым если выбран enc.
+// Сжатые варианты ищутся по ETag исходного payload.
+func (s *Server) writeRaw(w http.ResponseWriter, etag, enc string, payload []byte) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+
+	body := payload
+	if enc != "" {
+		v := s.variants.get(etag, payload)
+		compressed := v.gzip
+		if enc == encodingBrotli {
+			compressed = v.br
+		}
+		if compressed != nil {
+			body = compressed
+			h.Set("Content-Encoding", enc)
+		}
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	_, _ = w.Write(body)
+}
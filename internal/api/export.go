@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"yourmodule/internal/export"
+	"yourmodule/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exportOrders GET /orders:export — выгрузка заказов потоком из курсора БД.
+// Параметры: format=ndjson|csv|xlsx, from, to (date_created, [from, to)),
+// delivery_service, customer_id. Доступна только support и admin, поэтому без маскирования.
+func (s *Server) exportOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, err := export.ParseFormat(q.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter, err := exportFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "api.ExportOrders",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.route", "/orders:export"),
+			attribute.String("export.format", string(format)),
+		),
+	)
+	defer span.End()
+
+	// выгрузка длится дольше WriteTimeout сервера
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// заголовки отправляем с первой строкой: пока ничего не записано, ошибку ещё можно вернуть статусом
+	var ew export.Writer
+	open := func() error {
+		h := w.Header()
+		h.Set("Content-Type", format.ContentType())
+		h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+		h.Set("Cache-Control", "no-store")
+		var err error
+		ew, err = export.NewWriter(format, w)
+		return err
+	}
+
+	var n, corrupt int
+	err = s.store.StreamOrders(ctx, filter, func(so models.StoredOrder, rowErr error) error {
+		if rowErr != nil {
+			// битая строка не должна ломать всю выгрузку
+			corrupt++
+			slog.WarnContext(ctx, "corrupt order skipped in export", "order_uid", so.Order.OrderUID, "err", rowErr)
+			return nil
+		}
+		if ew == nil {
+			if err := open(); err != nil {
+				return err
+			}
+		}
+		n++
+		return ew.Write(so)
+	})
+	if err == nil && ew == nil {
+		err = open()
+	}
+	if err == nil {
+		err = ew.Close()
+	}
+	span.SetAttributes(attribute.Int("orders.exported", n), attribute.Int("orders.corrupt", corrupt))
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "export failed")
+	slog.ErrorContext(ctx, "order export failed", "format", format, "orders", n, "err", err)
+	if ew == nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	// ответ уже начат: рвём соединение, чтобы клиент не принял обрывок за файл целиком
+	panic(http.ErrAbortHandler)
+}
+
+// exportFilter разбирает фильтр выгрузки из query
+func exportFilter(q url.Values) (models.OrderFilter, error) {
+	f := models.OrderFilter{
+		DeliveryService: q.Get("delivery_service"),
+		CustomerID:      q.Get("customer_id"),
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = export.ParseTime(v); err != nil {
+			return f, fmt.Errorf("from: %w", err)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = export.ParseTime(v); err != nil {
+			return f, fmt.Errorf("to: %w", err)
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return f, errors.New("from must be before to")
+	}
+	return f, nil
+}
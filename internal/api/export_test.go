@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+)
+
+func TestExportOrders_CSV(t *testing.T) {
+	// битая строка пропускается, а не обрывает выгрузку
+	store := &fakeStore{orders: []models.StoredOrder{newFullOrder()}, corrupt: []string{"broken"}}
+	server := exportServer(t, store)
+
+	w := getWithKey(t, server, "/orders:export?format=csv&from=2026-01-01&to=2026-02-01&delivery_service=meest", "support")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "orders.csv") {
+		t.Fatalf("unexpected content disposition %s", cd)
+	}
+
+	want := models.OrderFilter{
+		From:            time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:              time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		DeliveryService: "meest",
+	}
+	if store.lastFilter != want {
+		t.Fatalf("unexpected filter %+v", store.lastFilter)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// заголовок и по строке на каждую из двух позиций
+	if len(records) != 3 || records[1][0] != "555" || records[2][0] != "555" {
+		t.Fatalf("unexpected records %v", records)
+	}
+}
+
+// exportServer сервер с ключом support: выгрузка без аутентификации закрыта
+func exportServer(t *testing.T, store *fakeStore) *Server {
+	t.Helper()
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Key: "partner", Subject: "p", Role: auth.RolePartner},
+		{Key: "support", Subject: "s", Role: auth.RoleSupport},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(store, newFakeCache(), WithAuth(a))
+}
+
+func TestExportOrders_Roles(t *testing.T) {
+	so := newFullOrder()
+	so.Payload = []byte(`{"order_uid":"555","delivery":{"email":"test@example.com"}}`)
+	server := exportServer(t, &fakeStore{orders: []models.StoredOrder{so}})
+
+	if w := getWithKey(t, server, "/orders:export", "partner"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for partner, got %d", w.Code)
+	}
+	w := getWithKey(t, server, "/orders:export", "support")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected ndjson, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "test@example.com") {
+		t.Fatalf("support must see PII: %s", w.Body)
+	}
+
+	// без аутентификации выгрузка закрыта, как admin API
+	w = httptest.NewRecorder()
+	NewServer(&fakeStore{}, newFakeCache()).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders:export", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+}
+
+func TestExportOrders_BadRequest(t *testing.T) {
+	server := exportServer(t, &fakeStore{})
+	for _, q := range []string{"format=xml", "from=yesterday", "from=2026-02-01&to=2026-01-01"} {
+		if w := getWithKey(t, server, "/orders:export?"+q, "support"); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestExportOrders_Empty(t *testing.T) {
+	w := getWithKey(t, exportServer(t, &fakeStore{}), "/orders:export?format=csv", "support")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "order_uid,") {
+		t.Fatalf("empty export must still have a header, got %d %q", w.Code, w.Body)
+	}
+}
+
+func TestExportOrders_StoreError(t *testing.T) {
+	// до первой строки ошибку ещё можно вернуть статусом
+	w := getWithKey(t, exportServer(t, &fakeStore{streamErr: errors.New("db down")}), "/orders:export", "support")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+
+	// после неё соединение рвётся, и клиент видит неполный ответ
+	store := &fakeStore{orders: []models.StoredOrder{newFullOrder()}, streamErr: errors.New("db down")}
+	srv := httptest.NewServer(exportServer(t, store).Routes())
+	t.Cleanup(srv.Close)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/orders:export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", "support")
+	resp, err := srv.Client().Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+	}
+	if err == nil {
+		t.Fatalf("expected broken response")
+	}
+}
@@ -3,39 +3,142 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"yourmodule/internal/auth"
+	cachepkg "yourmodule/internal/cache"
+	"yourmodule/internal/logging"
 	"yourmodule/internal/models"
+	"yourmodule/internal/ratelimit"
+	"yourmodule/internal/stream"
+	"yourmodule/internal/webhook"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("yourmodule/internal/api")
+
 /************* INTERFACES *************/
 
 type Store interface {
-	GetOrder(ctx context.Context, id string) (models.Order, []byte, error)
+	GetOrder(ctx context.Context, id string) (models.StoredOrder, error)
+	// GetOrders найденные заказы и uid заказов, чей payload в БД не разбирается
+	GetOrders(ctx context.Context, ids []string) (orders map[string]models.StoredOrder, corrupt []string, err error)
+	// StreamOrders отдаёт заказы под фильтр по одному, не собирая выборку в память;
+	// для строки с битым payload fn получает ошибку и может её пропустить
+	StreamOrders(ctx context.Context, f models.OrderFilter, fn func(models.StoredOrder, error) error) error
 }
 
+// Cache кэш заказов по order_uid
 type Cache interface {
-	Get(key string) (interface{}, bool)
-	Set(key string, val interface{}, ttl time.Duration)
+	Get(key string) (models.StoredOrder, bool)
+	Set(key string, so models.StoredOrder, ttl time.Duration)
 }
 
 /************* SERVER *************/
 
 type Server struct {
-	store Store
-	cache Cache
+	store      Store
+	cache      Cache
+	variants   *variantStore
+	auth       *auth.Authenticator
+	limits     *ratelimit.PerRoute
+	trustProxy bool
+	webhooks   webhook.Store
+	hub        *stream.Hub
+	batchLimit int
+	ready      func() error
+}
+
+// Option настраивает Server
+type Option func(*Server)
+
+// WithAuth включает аутентификацию для API; без неё API открыт
+func WithAuth(a *auth.Authenticator) Option {
+	return func(s *Server) { s.auth = a }
+}
+
+// WithRateLimit включает ограничение частоты запросов.
+// trustProxy — брать IP клиента из X-Forwarded-For.
+func WithRateLimit(limits *ratelimit.PerRoute, trustProxy bool) Option {
+	return func(s *Server) {
+		s.limits = limits
+		s.trustProxy = trustProxy
+	}
+}
+
+// WithWebhooks включает admin API подписок на события заказов.
+// Доступно только роли admin, поэтому без аутентификации закрыто.
+func WithWebhooks(store webhook.Store) Option {
+	return func(s *Server) { s.webhooks = store }
+}
+
+// WithStream включает GET /orders/stream с заказами, которые сохраняет
+// consumer этого же процесса
+func WithStream(h *stream.Hub) Option {
+	return func(s *Server) { s.hub = h }
+}
+
+// WithBatchLimit сколько заказов можно запросить одним POST /orders:batchGet
+func WithBatchLimit(n int) Option {
+	return func(s *Server) { s.batchLimit = n }
 }
 
-func NewServer(store Store, cache Cache) *Server {
-	return &Server{store: store, cache: cache}
+func NewServer(store Store, cache Cache, opts ...Option) *Server {
+	s := &Server{
+		store:      store,
+		cache:      cache,
+		variants:   &variantStore{cache: cachepkg.New[string, *variants](variantsTTL, time.Minute)},
+		batchLimit: DefaultBatchLimit,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) Routes() http.Handler {
 	r := mux.NewRouter()
-	r.HandleFunc("/order/{order_uid}", s.GetOrder).Methods(http.MethodGet)
+	r.Use(requestID)
+	// пробы балансировщика идут мимо аутентификации и лимитов
+	r.HandleFunc("/readyz", s.readyz).Methods(http.MethodGet)
+
+	api := r.NewRoute().Subrouter()
+	if s.auth != nil && s.limits != nil {
+		api.Use(s.limitAuthFailures)
+	}
+	if s.auth != nil {
+		api.Use(s.auth.Middleware, principalLogAttrs)
+	}
+	if s.limits != nil {
+		api.Use(s.rateLimit)
+	}
+	api.HandleFunc("/order/{order_uid}", s.GetOrder).Methods(http.MethodGet)
+	api.HandleFunc("/orders:batchGet", s.BatchGetOrders).Methods(http.MethodPost)
+	// выгрузка отдаёт заказы целиком и пачками, как admin API; без аутентификации закрыта
+	api.Handle("/orders:export", auth.RequireRole(auth.RoleSupport, auth.RoleAdmin)(http.HandlerFunc(s.exportOrders))).Methods(http.MethodGet)
+	api.Handle("/debug/vars", s.debugVars()).Methods(http.MethodGet)
+	if s.hub != nil {
+		api.HandleFunc("/orders/stream", s.streamOrders).Methods(http.MethodGet)
+	}
+
+	if s.webhooks != nil {
+		admin := api.PathPrefix("/admin").Subrouter()
+		admin.Use(auth.RequireRole(auth.RoleAdmin))
+		admin.HandleFunc("/webhooks", s.createWebhook).Methods(http.MethodPost)
+		admin.HandleFunc("/webhooks", s.listWebhooks).Methods(http.MethodGet)
+		admin.HandleFunc("/webhooks/{id}", s.getWebhook).Methods(http.MethodGet)
+		admin.HandleFunc("/webhooks/{id}", s.deleteWebhook).Methods(http.MethodDelete)
+		admin.HandleFunc("/webhooks/{id}/deliveries", s.listDeliveries).Methods(http.MethodGet)
+	}
+
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web")))
 	return r
 }
@@ -48,31 +151,99 @@ func (s *Server) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if v, ok := s.cache.Get(id); ok {
-		if ord, ok := v.(models.Order); ok {
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(ord); err != nil {
-				// обработка ошибки: отправляем 500 и логируем
-				http.Error(w, "internal server error", http.StatusInternalServerError)
-				return
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "api.GetOrder",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.route", "/order/{order_uid}"),
+			attribute.String("order.uid", id),
+		),
+	)
+	defer span.End()
+	ctx = logging.With(ctx, "order_uid", id)
+
+	rawFields := r.URL.Query().Get("fields")
+	fields, err := parseFields(rawFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	so, ok := s.lookupOrder(ctx, span, id)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	p, _ := auth.FromContext(r.Context())
+	showPII := s.auth == nil || privileged(p)
+
+	// без маскирования и проекции отдаём сохранённый payload без перекодирования
+	raw := showPII && fields == nil && len(so.Payload) > 0
+
+	// валидаторы зависят от payload и от формы ответа (маскирование, fields, сжатие)
+	baseETag := orderETag(so.Payload, showPII, rawFields)
+	etag := baseETag
+	var enc string
+	if raw {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if len(so.Payload) >= minCompressSize {
+			if enc = negotiateEncoding(r.Header.Get("Accept-Encoding")); enc != "" {
+				etag = encodedETag(baseETag, enc)
 			}
-			return
 		}
 	}
+	w.Header().Set("ETag", etag)
+	if !so.CreatedAt.IsZero() {
+		w.Header().Set("Last-Modified", so.CreatedAt.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if s.auth != nil {
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+	}
+	if notModified(r, etag, so.CreatedAt) {
+		span.SetAttributes(attribute.Bool("http.not_modified", true))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	// 2) db
-	ord, _, err := s.store.GetOrder(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	if raw {
+		span.SetAttributes(attribute.String("http.content_encoding", enc))
+		s.writeRaw(w, baseETag, enc, so.Payload)
 		return
 	}
 
-	s.cache.Set(id, ord, time.Minute)
+	body, err := shapeOrder(so.Order, showPII, fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(ord); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		// обработка ошибки: отправляем 500 и логируем
+		slog.ErrorContext(ctx, "encode order failed", "err", err)
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
+}
+
+// lookupOrder ищет заказ сначала в кэше, затем в БД
+func (s *Server) lookupOrder(ctx context.Context, span trace.Span, id string) (models.StoredOrder, bool) {
+	if so, ok := s.cache.Get(id); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return so, true
+	}
+
+	// 2) db
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	so, err := s.store.GetOrder(ctx, id)
+	if err != nil {
+		span.SetStatus(codes.Error, "not found")
+		slog.DebugContext(ctx, "order lookup failed", "err", err)
+		return models.StoredOrder{}, false
+	}
 
+	s.cache.Set(id, so, 0)
+	return so, true
 }
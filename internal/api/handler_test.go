@@ -1,55 +1,106 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"yourmodule/internal/auth"
 	"yourmodule/internal/models"
+	"yourmodule/internal/ratelimit"
+
+	"github.com/andybalholm/brotli"
 )
 
 /************* FAKE CACHE *************/
 
 type fakeCache struct {
-	data map[string]interface{}
+	data map[string]models.StoredOrder
 }
 
 func newFakeCache() *fakeCache {
-	return &fakeCache{data: map[string]interface{}{}}
+	return &fakeCache{data: map[string]models.StoredOrder{}}
 }
 
-func (f *fakeCache) Get(key string) (interface{}, bool) {
+func (f *fakeCache) Get(key string) (models.StoredOrder, bool) {
 	v, ok := f.data[key]
 	return v, ok
 }
 
-func (f *fakeCache) Set(key string, val interface{}, _ time.Duration) {
+func (f *fakeCache) Set(key string, val models.StoredOrder, _ time.Duration) {
 	f.data[key] = val
 }
 
 /************* FAKE STORE *************/
 
-type fakeStore struct{}
+type fakeStore struct {
+	// batchCalls аргументы вызовов GetOrders
+	batchCalls [][]string
+	// orders то, что отдаёт StreamOrders, и фильтр последнего вызова
+	orders     []models.StoredOrder
+	corrupt    []string
+	lastFilter models.OrderFilter
+	streamErr  error
+}
 
-func (f *fakeStore) GetOrder(ctx context.Context, id string) (models.Order, []byte, error) {
+func (f *fakeStore) GetOrder(ctx context.Context, id string) (models.StoredOrder, error) {
 	if id == "123" {
-		return models.Order{
-			OrderUID:    "123",
-			TrackNumber: "WB123",
-		}, nil, nil
+		return models.StoredOrder{
+			Order: models.Order{
+				OrderUID:    "123",
+				TrackNumber: "WB123",
+			},
+			Payload:   []byte(`{"order_uid":"123","track_number":"WB123"}`),
+			CreatedAt: time.Date(2026, 1, 16, 15, 58, 0, 0, time.UTC),
+		}, nil
+	}
+	return models.StoredOrder{}, errors.New("not found")
+}
+
+func (f *fakeStore) GetOrders(ctx context.Context, ids []string) (map[string]models.StoredOrder, []string, error) {
+	f.batchCalls = append(f.batchCalls, ids)
+	out := map[string]models.StoredOrder{}
+	var corrupt []string
+	for _, id := range ids {
+		if id == "broken" {
+			corrupt = append(corrupt, id)
+			continue
+		}
+		if so, err := f.GetOrder(ctx, id); err == nil {
+			out[id] = so
+		}
 	}
-	return models.Order{}, nil, errors.New("not found")
+	return out, corrupt, nil
+}
+
+func (f *fakeStore) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(models.StoredOrder, error) error) error {
+	f.lastFilter = filter
+	for _, uid := range f.corrupt {
+		if err := fn(models.StoredOrder{Order: models.Order{OrderUID: uid}}, errors.New("corrupt payload")); err != nil {
+			return err
+		}
+	}
+	for _, so := range f.orders {
+		if err := fn(so, nil); err != nil {
+			return err
+		}
+	}
+	return f.streamErr
 }
 
 /************* TESTS *************/
 
 func TestGetOrder_FromCache(t *testing.T) {
 	cache := newFakeCache()
-	cache.Set("123", models.Order{OrderUID: "123"}, time.Minute)
+	cache.Set("123", models.StoredOrder{Order: models.Order{OrderUID: "123"}}, time.Minute)
 
 	server := NewServer(&fakeStore{}, cache)
 
@@ -97,3 +148,394 @@ func TestGetOrder_NotFound(t *testing.T) {
 		t.Fatalf("expected 404, got %d", w.Code)
 	}
 }
+
+func TestRequestID_Echo(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache())
+
+	req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+
+	server.Routes().ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected echoed request id, got %q", got)
+	}
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache())
+
+	req := httptest.NewRequest(http.MethodGet, "/order/999", nil)
+	w := httptest.NewRecorder()
+
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Fatalf("expected generated request id")
+	}
+}
+
+func TestGetOrder_AuthRequired(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Key: "k", Subject: "s", Role: auth.RoleSupport}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(&fakeStore{}, newFakeCache(), WithAuth(a))
+
+	req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.Header.Set("X-API-Key", "k")
+	w = httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+}
+
+func newFullOrder() models.StoredOrder {
+	return models.StoredOrder{Order: models.Order{
+		OrderUID: "555",
+		Delivery: models.Delivery{
+			Name:    "Test User",
+			Phone:   "+1234567890",
+			City:    "City",
+			Address: "Street 1",
+			Email:   "test@example.com",
+		},
+		Payment: models.Payment{Transaction: "tx-abcdef123"},
+		Items:   []models.Item{{ChrtID: 1, Price: 10, Name: "a"}, {ChrtID: 2, Price: 20, Name: "b"}},
+	}}
+}
+
+func getWithKey(t *testing.T, server *Server, url, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	return w
+}
+
+func TestGetOrder_MaskedForPartner(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Key: "partner", Subject: "p", Role: auth.RolePartner},
+		{Key: "support", Subject: "s", Role: auth.RoleSupport},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newFakeCache()
+	cache.Set("555", newFullOrder(), time.Minute)
+	server := NewServer(&fakeStore{}, cache, WithAuth(a))
+
+	var ord models.Order
+	w := getWithKey(t, server, "/order/555", "partner")
+	if err := json.NewDecoder(w.Body).Decode(&ord); err != nil {
+		t.Fatal(err)
+	}
+	if ord.Delivery.Phone != "*********90" || ord.Delivery.Email != "t***@example.com" ||
+		ord.Delivery.Address != "********" || ord.Payment.Transaction != "********f123" {
+		t.Fatalf("PII not masked: %+v %+v", ord.Delivery, ord.Payment)
+	}
+	if ord.Delivery.City != "City" {
+		t.Fatalf("non-PII field must stay intact, got %q", ord.Delivery.City)
+	}
+
+	ord = models.Order{}
+	w = getWithKey(t, server, "/order/555", "support")
+	if err := json.NewDecoder(w.Body).Decode(&ord); err != nil {
+		t.Fatal(err)
+	}
+	if ord.Delivery.Phone != "+1234567890" {
+		t.Fatalf("support must see PII, got %q", ord.Delivery.Phone)
+	}
+}
+
+func TestGetOrder_FieldsProjection(t *testing.T) {
+	cache := newFakeCache()
+	cache.Set("555", newFullOrder(), time.Minute)
+	server := NewServer(&fakeStore{}, cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/555?fields=order_uid,delivery.city,items.price", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var got map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["order_uid"] != "555" {
+		t.Fatalf("unexpected projection: %v", got)
+	}
+	delivery := got["delivery"].(map[string]any)
+	if len(delivery) != 1 || delivery["city"] != "City" {
+		t.Fatalf("unexpected delivery projection: %v", delivery)
+	}
+	items := got["items"].([]any)
+	if len(items) != 2 || len(items[0].(map[string]any)) != 1 {
+		t.Fatalf("unexpected items projection: %v", items)
+	}
+}
+
+func TestGetOrder_ExtraHiddenFromPartner(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Key: "partner", Subject: "p", Role: auth.RolePartner},
+		{Key: "support", Subject: "s", Role: auth.RoleSupport},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	so := newFullOrder()
+	so.Order.Extra = models.Extra{"passport": json.RawMessage(`"4510 123456"`)}
+	so.Order.Delivery.Extra = models.Extra{"second_phone": json.RawMessage(`"+1999"`)}
+	so.Order.Payment.Extra = models.Extra{"card_holder": json.RawMessage(`"TEST USER"`)}
+	so.Order.Items[0].Extra = models.Extra{"gift_note": json.RawMessage(`"for Test User"`)}
+	cache := newFakeCache()
+	cache.Set("555", so, time.Minute)
+	server := NewServer(&fakeStore{}, cache, WithAuth(a))
+
+	body := getWithKey(t, server, "/order/555", "partner").Body.String()
+	for _, leak := range []string{"passport", "second_phone", "card_holder", "gift_note"} {
+		if strings.Contains(body, leak) {
+			t.Fatalf("unknown field %s must be hidden from partner: %s", leak, body)
+		}
+	}
+
+	// маскирование не трогает заказ в кэше
+	body = getWithKey(t, server, "/order/555", "support").Body.String()
+	for _, field := range []string{"passport", "second_phone", "card_holder", "gift_note"} {
+		if !strings.Contains(body, field) {
+			t.Fatalf("support must see unknown field %s: %s", field, body)
+		}
+	}
+}
+
+func TestGetOrder_FieldsOfNull(t *testing.T) {
+	so := newFullOrder()
+	so.Order.Items = nil
+	cache := newFakeCache()
+	cache.Set("555", so, time.Minute)
+	server := NewServer(&fakeStore{}, cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/555?fields=order_uid,items.price", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got["items"]; !ok || v != nil {
+		t.Fatalf("expected items: null, got %v", got)
+	}
+}
+
+func TestGetOrder_FieldsUnknown(t *testing.T) {
+	cache := newFakeCache()
+	cache.Set("555", newFullOrder(), time.Minute)
+	server := NewServer(&fakeStore{}, cache)
+
+	for _, fields := range []string{"nope", "order_uid.x", "delivery..city"} {
+		req := httptest.NewRequest(http.MethodGet, "/order/555?fields="+fields, nil)
+		w := httptest.NewRecorder()
+		server.Routes().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("fields=%s: expected 400, got %d", fields, w.Code)
+		}
+	}
+}
+
+func TestGetOrder_RateLimited(t *testing.T) {
+	limits := ratelimit.NewPerRoute(ratelimit.Rule{RPS: 1, Burst: 2}, nil)
+	server := NewServer(&fakeStore{}, newFakeCache(), WithRateLimit(limits, false))
+	routes := server.Routes()
+
+	codes := make([]int, 3)
+	var w *httptest.ResponseRecorder
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+		w = httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status codes: %v", codes)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected Retry-After: 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// другой клиент не затронут
+	req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for another client, got %d", w.Code)
+	}
+}
+
+func TestAuthFailures_RateLimited(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Key: "good", Subject: "s", Role: auth.RoleSupport}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := ratelimit.NewPerRoute(ratelimit.Rule{RPS: 100, Burst: 100},
+		map[string]ratelimit.Rule{authFailuresRoute: {RPS: 0.001, Burst: 2}})
+	server := NewServer(&fakeStore{}, newFakeCache(), WithAuth(a), WithRateLimit(limits, false))
+
+	// неудачные попытки списывают токены IP, хотя до rateLimit не доходят
+	for i := 0; i < 2; i++ {
+		if w := getWithKey(t, server, "/order/123", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, w.Code)
+		}
+	}
+	w := getWithKey(t, server, "/order/123", "guess")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 after failed attempts, got %d", w.Code)
+	}
+
+	// другой IP не затронут, успешные запросы токенов неудач не тратят
+	req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-API-Key", "good")
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		server.Routes().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200 for another client, got %d", w.Code)
+		}
+	}
+}
+
+func TestGetOrder_ConditionalGET(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache())
+	routes := server.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") != "Fri, 16 Jan 2026 15:58:00 GMT" {
+		t.Fatalf("missing validators: %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected empty 304, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/order/123?fields=order_uid", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("projection must have its own ETag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.Header.Set("If-Modified-Since", "Fri, 16 Jan 2026 15:58:00 GMT")
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/order/123", nil)
+	req.Header.Set("If-Modified-Since", "Thu, 15 Jan 2026 00:00:00 GMT")
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for stale If-Modified-Since, got %d", w.Code)
+	}
+}
+
+func TestGetOrder_RawPayload(t *testing.T) {
+	// неизвестные модели поля должны дойти до клиента как есть
+	payload := []byte(`{"order_uid":"777","x_upstream":{"a":1}}`)
+	cache := newFakeCache()
+	cache.Set("777", models.StoredOrder{Order: models.Order{OrderUID: "777"}, Payload: payload}, time.Minute)
+	server := NewServer(&fakeStore{}, cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/777", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Fatalf("expected stored payload, got %s", w.Body.String())
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("small payload must not be compressed")
+	}
+}
+
+func TestGetOrder_Compressed(t *testing.T) {
+	payload := []byte(`{"order_uid":"888","items":[` + strings.Repeat(`{"name":"item"},`, 100) + `{}]}`)
+	cache := newFakeCache()
+	cache.Set("888", models.StoredOrder{Order: models.Order{OrderUID: "888"}, Payload: payload}, time.Minute)
+	server := NewServer(&fakeStore{}, cache)
+	routes := server.Routes()
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+
+	etags := map[string]bool{}
+	for _, tt := range []struct{ accept, want string }{
+		{"gzip, deflate, br", "br"},
+		{"gzip", "gzip"},
+		{"br;q=0.5, gzip;q=0.8", "gzip"},
+		{"identity", ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/order/888", nil)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+
+		got := w.Header().Get("Content-Encoding")
+		if got != tt.want {
+			t.Fatalf("Accept-Encoding %q: expected %q, got %q", tt.accept, tt.want, got)
+		}
+		etags[w.Header().Get("ETag")] = true
+
+		body := io.Reader(w.Body)
+		if dec, ok := decoders[got]; ok {
+			r, err := dec(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = r
+		}
+		plain, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, payload) {
+			t.Fatalf("Accept-Encoding %q: payload mismatch", tt.accept)
+		}
+	}
+
+	if len(etags) != 3 {
+		t.Fatalf("each encoding must have its own ETag, got %v", etags)
+	}
+}
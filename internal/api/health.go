@@ -0,0 +1,56 @@
+package api
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"yourmodule/internal/auth"
+)
+
+// WithReadiness задаёт проверку готовности для GET /readyz, например окончание
+// прогрева кэша. Без неё сервер готов сразу.
+func WithReadiness(ready func() error) Option {
+	return func(s *Server) { s.ready = ready }
+}
+
+// readyz GET /readyz для балансировщика и Kubernetes: 200, когда можно принимать
+// трафик, иначе 503 с причиной
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.ready != nil {
+		if err := s.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// debugVars GET /debug/vars: метрики expvar, только для admin. Без аутентификации
+// закрыт, как и admin API.
+func (s *Server) debugVars() http.Handler {
+	return auth.RequireRole(auth.RoleAdmin)(http.HandlerFunc(expvarMetrics))
+}
+
+// hiddenVars переменные expvar, которые не отдаются наружу: в командной строке
+// процесса бывают пароли и ключи, переданные флагами
+var hiddenVars = map[string]bool{"cmdline": true}
+
+// expvarMetrics как expvar.Handler, но без hiddenVars
+func expvarMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if hiddenVars[kv.Key] {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
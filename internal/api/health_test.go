@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yourmodule/internal/auth"
+)
+
+func TestReadyz(t *testing.T) {
+	var notReady error = errors.New("cache warmup in progress")
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Key: "k", Subject: "s", Role: auth.RoleSupport}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(&fakeStore{}, newFakeCache(), WithAuth(a), WithReadiness(func() error { return notReady }))
+
+	// проба без ключа: аутентификация её не касается
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "warmup") {
+		t.Fatalf("expected 503 while warming up, got %d %q", w.Code, w.Body.String())
+	}
+
+	notReady = nil
+	w = httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when ready, got %d", w.Code)
+	}
+}
+
+func TestDebugVars_AdminOnly(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Key: "support", Subject: "s", Role: auth.RoleSupport},
+		{Key: "admin", Subject: "a", Role: auth.RoleAdmin},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(&fakeStore{}, newFakeCache(), WithAuth(a))
+
+	if w := getWithKey(t, server, "/debug/vars", "support"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for support, got %d", w.Code)
+	}
+	w := getWithKey(t, server, "/debug/vars", "admin")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"memstats"`) {
+		t.Fatalf("expected expvar dump for admin, got %d", w.Code)
+	}
+	if !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("invalid JSON: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `"cmdline"`) {
+		t.Fatalf("command line must not be exposed")
+	}
+}
+
+func TestDebugVars_ClosedWithoutAuth(t *testing.T) {
+	server := NewServer(&fakeStore{}, newFakeCache())
+
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+}
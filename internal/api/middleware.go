@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// statusRecorder запоминает код ответа для access-лога
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного writer'а
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// requestID берёт X-Request-ID клиента или генерирует новый,
+// возвращает его в ответе и кладёт в контекст логирования
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := logging.With(r.Context(), "request_id", id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		slog.InfoContext(ctx, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// principalLogAttrs добавляет клиента в контекст логирования
+func principalLogAttrs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.FromContext(r.Context()); ok {
+			ctx := logging.With(r.Context(), "subject", p.Subject, "role", p.Role)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit ограничивает частоту запросов клиента на маршрут.
+// Клиент — subject API-ключа/JWT, а для анонимных запросов IP-адрес.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.charge(w, r, 1) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// charge списывает n токенов клиента на текущем маршруте. Если токенов нет,
+// отвечает 429 и возвращает false.
+func (s *Server) charge(w http.ResponseWriter, r *http.Request, n int) bool {
+	if s.limits == nil || n <= 0 {
+		return true
+	}
+	route := r.URL.Path
+	if cur := mux.CurrentRoute(r); cur != nil {
+		if tpl, err := cur.GetPathTemplate(); err == nil {
+			route = tpl
+		}
+	}
+
+	key := "ip:" + s.clientIP(r)
+	if p, ok := auth.FromContext(r.Context()); ok {
+		key = "sub:" + p.Subject
+	}
+
+	ok, retryAfter := s.limits.For(route).AllowN(key, n)
+	if !ok {
+		tooManyRequests(w, r, route, key, retryAfter)
+	}
+	return ok
+}
+
+// authFailuresRoute имя правила в RATE_LIMIT_ROUTES для неудачных попыток аутентификации
+const authFailuresRoute = "auth"
+
+// limitAuthFailures стоит перед аутентификацией, которую rateLimit не видит:
+// каждый ответ 401 списывает токен с IP клиента, а IP без токенов получает 429,
+// не доходя до проверки ключа. Так ограничен перебор ключей и токенов.
+func (s *Server) limitAuthFailures(next http.Handler) http.Handler {
+	limiter := s.limits.For(authFailuresRoute)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + s.clientIP(r)
+		if blocked, retryAfter := limiter.Blocked(key); blocked {
+			tooManyRequests(w, r, authFailuresRoute, key, retryAfter)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status == http.StatusUnauthorized {
+			limiter.Allow(key)
+		}
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, route, key string, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	slog.WarnContext(r.Context(), "rate limit exceeded", "route", route, "client", key)
+	http.Error(w, "too many requests", http.StatusTooManyRequests)
+}
+
+// clientIP адрес клиента; X-Forwarded-For учитывается только за доверенным прокси
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
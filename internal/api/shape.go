@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+)
+
+const maskChar = "*"
+
+// privileged видит персональные данные без маскирования
+func privileged(p auth.Principal) bool {
+	return p.Role == auth.RoleSupport || p.Role == auth.RoleAdmin
+}
+
+// shapeOrder маскирует PII для непривилегированных клиентов
+// и оставляет только запрошенные поля
+func shapeOrder(ord models.Order, showPII bool, fields fieldTree) (any, error) {
+	if !showPII {
+		ord = maskOrder(ord)
+	}
+	if fields == nil {
+		return ord, nil
+	}
+
+	// проекция делается по JSON-представлению, чтобы пути совпадали с именами в ответе
+	raw, err := json.Marshal(ord)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return fields.project(doc, "")
+}
+
+// maskOrder возвращает копию заказа со скрытыми контактами и транзакцией.
+// Неизвестные модели поля отбрасываются: что в них, неизвестно, и замаскировать
+// их поштучно нельзя.
+func maskOrder(ord models.Order) models.Order {
+	ord.Delivery.Phone = maskTail(ord.Delivery.Phone, 2)
+	ord.Delivery.Email = maskEmail(ord.Delivery.Email)
+	ord.Delivery.Address = maskTail(ord.Delivery.Address, 0)
+	ord.Payment.Transaction = maskTail(ord.Payment.Transaction, 4)
+
+	ord.Extra = nil
+	ord.Delivery.Extra = nil
+	ord.Payment.Extra = nil
+	if ord.Items != nil {
+		// товары общие с заказом в кэше, меняем копию
+		items := make([]models.Item, len(ord.Items))
+		for i, it := range ord.Items {
+			it.Extra = nil
+			items[i] = it
+		}
+		ord.Items = items
+	}
+	return ord
+}
+
+// maskTail заменяет всё, кроме последних keep символов
+func maskTail(s string, keep int) string {
+	r := []rune(s)
+	if len(r) <= keep*2 {
+		// короткое значение раскрыло бы слишком много — прячем целиком
+		return strings.Repeat(maskChar, len(r))
+	}
+	return strings.Repeat(maskChar, len(r)-keep) + string(r[len(r)-keep:])
+}
+
+// maskEmail оставляет первую букву и домен: j***@example.com
+func maskEmail(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return maskTail(s, 0)
+	}
+	r := []rune(local)
+	return string(r[0]) + strings.Repeat(maskChar, 3) + "@" + domain
+}
+
+// fieldTree дерево запрошенных полей; пустой узел — поле целиком
+type fieldTree map[string]fieldTree
+
+// parseFields разбирает fields=order_uid,delivery.city,items.price
+func parseFields(s string) (fieldTree, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	root := fieldTree{}
+	for _, path := range strings.Split(s, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		node := root
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid field %q", path)
+			}
+			child, ok := node[part]
+			if ok && len(child) == 0 {
+				// поле уже запрошено целиком
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = fieldTree{}
+				break
+			}
+			if !ok {
+				child = fieldTree{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	if len(root) == 0 {
+		return nil, nil
+	}
+	return root, nil
+}
+
+// project оставляет в документе только поля из дерева.
+// Для массивов проекция применяется к каждому элементу.
+func (t fieldTree) project(v any, prefix string) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for name, sub := range t {
+			val, ok := v[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", prefix+name)
+			}
+			// у отсутствующего значения (null) подполя тоже отсутствуют
+			if len(sub) == 0 || val == nil {
+				out[name] = val
+				continue
+			}
+			p, err := sub.project(val, prefix+name+".")
+			if err != nil {
+				return nil, err
+			}
+			out[name] = p
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, el := range v {
+			p, err := t.project(el, prefix)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = p
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("field %q has no subfields", strings.TrimSuffix(prefix, "."))
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+	"yourmodule/internal/stream"
+)
+
+// streamHeartbeat как часто слать комментарий, чтобы прокси не рвали соединение
+const streamHeartbeat = 15 * time.Second
+
+// orderSummary краткое представление заказа для потока
+type orderSummary struct {
+	OrderUID        string `json:"order_uid"`
+	TrackNumber     string `json:"track_number"`
+	CustomerID      string `json:"customer_id"`
+	DeliveryService string `json:"delivery_service"`
+	Amount          int    `json:"amount"`
+	Currency        string `json:"currency"`
+	Items           int    `json:"items"`
+	DateCreated     string `json:"date_created"`
+}
+
+func summarize(o models.Order) orderSummary {
+	return orderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		Items:           len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
+
+// streamOrders GET /orders/stream — Server-Sent Events с новыми заказами.
+// Параметры: delivery_service, customer_id — фильтры; mode=summary (по умолчанию)
+// или mode=full — заказ целиком с маскированием и fields, как в GET /order/{id}.
+func (s *Server) streamOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	full := false
+	switch q.Get("mode") {
+	case "", "summary":
+	case "full":
+		full = true
+	default:
+		http.Error(w, "mode must be summary or full", http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields(q.Get("fields"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, _ := auth.FromContext(r.Context())
+	showPII := s.auth == nil || privileged(p)
+
+	sub, err := s.hub.Subscribe(stream.Filter{
+		DeliveryService: q.Get("delivery_service"),
+		CustomerID:      q.Get("customer_id"),
+	})
+	if errors.Is(err, stream.ErrTooManySubscribers) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	// поток живёт дольше WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// подсказка EventSource, через сколько переподключаться
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		slog.WarnContext(r.Context(), "streaming not supported", "err", err)
+		return
+	}
+
+	ctx := r.Context()
+	slog.DebugContext(ctx, "stream subscriber connected", "subscribers", s.hub.Subscribers())
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-sub.Events():
+			if !ok {
+				// отстал от потока — отключаем, клиент переподключится
+				return
+			}
+			var body any = summarize(ev.Order.Order)
+			if full {
+				if body, err = shapeOrder(ev.Order.Order, showPII, fields); err != nil {
+					slog.ErrorContext(ctx, "shape streamed order failed", "err", err)
+					continue
+				}
+			}
+			data, err := json.Marshal(body)
+			if err != nil {
+				slog.ErrorContext(ctx, "encode streamed order failed", "err", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
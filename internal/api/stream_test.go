@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/models"
+	"yourmodule/internal/stream"
+)
+
+// readEvent читает одно SSE-событие (строки до пустой), пропуская комментарии
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	ev := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if _, ok := ev["data"]; ok {
+				return ev
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		k, v, _ := strings.Cut(line, ": ")
+		ev[k] = v
+	}
+}
+
+func openStream(t *testing.T, srv *httptest.Server, hub *stream.Hub, path, key string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// ждём, пока обработчик подпишется
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.Subscribers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscriber not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestStreamOrders_Summary(t *testing.T) {
+	hub := stream.NewHub(8, 0)
+	srv := httptest.NewServer(NewServer(&fakeStore{}, newFakeCache(), WithStream(hub)).Routes())
+	t.Cleanup(srv.Close)
+
+	r := openStream(t, srv, hub, "/orders/stream?delivery_service=meest", "")
+
+	skip := newFullOrder()
+	skip.Order.DeliveryService = "cdek"
+	hub.Notify(context.Background(), models.EventOrderCreated, skip)
+	so := newFullOrder()
+	so.Order.DeliveryService = "meest"
+	so.Order.Payment.Amount = 1817
+	hub.Notify(context.Background(), models.EventOrderUpdated, so)
+
+	ev := readEvent(t, r)
+	if ev["event"] != models.EventOrderUpdated || ev["id"] != "2" {
+		t.Fatalf("expected filtered update event, got %v", ev)
+	}
+	var got orderSummary
+	if err := json.Unmarshal([]byte(ev["data"]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.OrderUID != "555" || got.Amount != 1817 || got.Items != 2 {
+		t.Fatalf("unexpected summary: %+v", got)
+	}
+	if strings.Contains(ev["data"], "test@example.com") {
+		t.Fatalf("summary must not include contacts: %s", ev["data"])
+	}
+}
+
+func TestStreamOrders_FullMasked(t *testing.T) {
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{{Key: "partner", Subject: "p", Role: auth.RolePartner}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := stream.NewHub(8, 0)
+	srv := httptest.NewServer(NewServer(&fakeStore{}, newFakeCache(), WithAuth(a), WithStream(hub)).Routes())
+	t.Cleanup(srv.Close)
+
+	r := openStream(t, srv, hub, "/orders/stream?mode=full", "partner")
+	hub.Notify(context.Background(), models.EventOrderCreated, newFullOrder())
+
+	ev := readEvent(t, r)
+	var got models.Order
+	if err := json.Unmarshal([]byte(ev["data"]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.OrderUID != "555" || got.Delivery.Email != "t***@example.com" {
+		t.Fatalf("expected masked full order, got %+v", got.Delivery)
+	}
+}
+
+func TestStreamOrders_Errors(t *testing.T) {
+	hub := stream.NewHub(8, 1)
+	server := NewServer(&fakeStore{}, newFakeCache(), WithStream(hub))
+
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/stream?mode=raw", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown mode, got %d", w.Code)
+	}
+
+	sub, _ := hub.Subscribe(stream.Filter{})
+	defer sub.Close()
+	w = httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/stream", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 when subscribers limit reached, got %d", w.Code)
+	}
+}
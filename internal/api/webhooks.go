@@ -0,0 +1,184 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"yourmodule/internal/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// webhookRequest тело POST /admin/webhooks
+type webhookRequest struct {
+	URL              string   `json:"url"`
+	Secret           string   `json:"secret"`
+	EventTypes       []string `json:"event_types"`
+	DeliveryServices []string `json:"delivery_services"`
+}
+
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hook := webhook.Webhook{
+		URL:              req.URL,
+		Secret:           req.Secret,
+		EventTypes:       req.EventTypes,
+		DeliveryServices: req.DeliveryServices,
+	}
+	if err := hook.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hook.Secret == "" {
+		hook.Secret = newSecret()
+	}
+
+	created, err := s.webhooks.CreateWebhook(r.Context(), hook)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create webhook failed", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "webhook created", "webhook_id", created.ID, "url", created.URL)
+
+	// секрет показываем только при создании
+	w.Header().Set("Location", "/admin/webhooks/"+strconv.FormatInt(created.ID, 10))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list webhooks failed", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	out := make([]webhook.Webhook, 0, len(hooks))
+	for _, h := range hooks {
+		h.Secret = ""
+		out = append(out, h)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	err := s.webhooks.DeleteWebhook(r.Context(), id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete webhook failed", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "webhook deleted", "webhook_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDeliveries журнал доставок: ?status=failed&limit=100
+func (s *Server) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := s.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	f := webhook.DeliveryFilter{Status: webhook.Status(q.Get("status")), Limit: defaultDeliveriesLimit}
+	switch f.Status {
+	case "", webhook.StatusPending, webhook.StatusSucceeded, webhook.StatusFailed:
+	default:
+		http.Error(w, "status must be pending, succeeded or failed", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeliveriesLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxDeliveriesLimit), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	deliveries, err := s.webhooks.ListDeliveries(r.Context(), hook.ID, f)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list webhook deliveries failed", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []webhook.Delivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// findWebhook достаёт webhook по {id} из пути, при ошибке сам пишет ответ
+func (s *Server) findWebhook(w http.ResponseWriter, r *http.Request) (webhook.Webhook, bool) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return webhook.Webhook{}, false
+	}
+	hook, err := s.webhooks.GetWebhook(r.Context(), id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return hook, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get webhook failed", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return hook, false
+	}
+	return hook, true
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid webhook id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// newSecret случайный секрет для подписи, если администратор не задал свой
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yourmodule/internal/auth"
+	"yourmodule/internal/webhook"
+)
+
+/************* FAKE WEBHOOK STORE *************/
+
+type fakeWebhookStore struct {
+	hooks      map[int64]webhook.Webhook
+	deliveries []webhook.Delivery
+	lastFilter webhook.DeliveryFilter
+}
+
+func newFakeWebhookStore() *fakeWebhookStore {
+	return &fakeWebhookStore{hooks: map[int64]webhook.Webhook{}}
+}
+
+func (f *fakeWebhookStore) CreateWebhook(ctx context.Context, w webhook.Webhook) (webhook.Webhook, error) {
+	w.ID = int64(len(f.hooks) + 1)
+	f.hooks[w.ID] = w
+	return w, nil
+}
+
+func (f *fakeWebhookStore) ListWebhooks(ctx context.Context) ([]webhook.Webhook, error) {
+	var out []webhook.Webhook
+	for _, h := range f.hooks {
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func (f *fakeWebhookStore) GetWebhook(ctx context.Context, id int64) (webhook.Webhook, error) {
+	h, ok := f.hooks[id]
+	if !ok {
+		return h, webhook.ErrNotFound
+	}
+	return h, nil
+}
+
+func (f *fakeWebhookStore) DeleteWebhook(ctx context.Context, id int64) error {
+	if _, ok := f.hooks[id]; !ok {
+		return webhook.ErrNotFound
+	}
+	delete(f.hooks, id)
+	return nil
+}
+
+func (f *fakeWebhookStore) CreateDelivery(ctx context.Context, d webhook.Delivery) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeWebhookStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	return nil, nil
+}
+
+func (f *fakeWebhookStore) UpdateDelivery(ctx context.Context, d webhook.Delivery) error { return nil }
+
+func (f *fakeWebhookStore) ListDeliveries(ctx context.Context, id int64, filter webhook.DeliveryFilter) ([]webhook.Delivery, error) {
+	f.lastFilter = filter
+	return f.deliveries, nil
+}
+
+/************* TESTS *************/
+
+func newAdminServer(t *testing.T, store *fakeWebhookStore) *Server {
+	t.Helper()
+	a, err := auth.New(auth.Config{APIKeys: []auth.APIKey{
+		{Key: "admin", Subject: "ops", Role: auth.RoleAdmin},
+		{Key: "support", Subject: "s", Role: auth.RoleSupport},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(&fakeStore{}, newFakeCache(), WithAuth(a), WithWebhooks(store))
+}
+
+func adminRequest(server *Server, method, url, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhooks_AdminOnly(t *testing.T) {
+	server := newAdminServer(t, newFakeWebhookStore())
+
+	if w := adminRequest(server, http.MethodGet, "/admin/webhooks", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got %d", w.Code)
+	}
+	if w := adminRequest(server, http.MethodGet, "/admin/webhooks", "support", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for support, got %d", w.Code)
+	}
+
+	// без аутентификации admin API закрыто, а не открыто всем
+	open := NewServer(&fakeStore{}, newFakeCache(), WithWebhooks(newFakeWebhookStore()))
+	if w := adminRequest(open, http.MethodGet, "/admin/webhooks", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 when auth is disabled, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_CRUD(t *testing.T) {
+	store := newFakeWebhookStore()
+	server := newAdminServer(t, store)
+
+	w := adminRequest(server, http.MethodPost, "/admin/webhooks", "admin",
+		`{"url":"https://partner.example/hook","event_types":["order.created"],"delivery_services":["meest"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created webhook.Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != 1 || len(created.Secret) != 64 || w.Header().Get("Location") != "/admin/webhooks/1" {
+		t.Fatalf("expected generated secret and location, got %+v %v", created, w.Header())
+	}
+
+	// секрет не возвращается повторно
+	w = adminRequest(server, http.MethodGet, "/admin/webhooks", "admin", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) {
+		t.Fatalf("list must hide secrets, got %d: %s", w.Code, w.Body)
+	}
+	w = adminRequest(server, http.MethodGet, "/admin/webhooks/1", "admin", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) {
+		t.Fatalf("get must hide secret, got %d: %s", w.Code, w.Body)
+	}
+
+	if w := adminRequest(server, http.MethodDelete, "/admin/webhooks/1", "admin", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := adminRequest(server, http.MethodDelete, "/admin/webhooks/1", "admin", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_CreateInvalid(t *testing.T) {
+	server := newAdminServer(t, newFakeWebhookStore())
+
+	for _, body := range []string{
+		`{"url":"not a url"}`,
+		`{"url":"https://partner.example/hook","event_types":["order.deleted"]}`,
+		`{"url":"https://partner.example/hook","unknown":1}`,
+		`{`,
+	} {
+		if w := adminRequest(server, http.MethodPost, "/admin/webhooks", "admin", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestWebhooks_Deliveries(t *testing.T) {
+	store := newFakeWebhookStore()
+	store.hooks[1] = webhook.Webhook{ID: 1, URL: "https://partner.example/hook"}
+	store.deliveries = []webhook.Delivery{{ID: 7, WebhookID: 1, Status: webhook.StatusFailed, Attempts: 6}}
+	server := newAdminServer(t, store)
+
+	w := adminRequest(server, http.MethodGet, "/admin/webhooks/1/deliveries?status=failed&limit=10", "admin", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got []webhook.Delivery
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != 7 {
+		t.Fatalf("unexpected deliveries: %+v", got)
+	}
+	if store.lastFilter.Status != webhook.StatusFailed || store.lastFilter.Limit != 10 {
+		t.Fatalf("filter not passed to store: %+v", store.lastFilter)
+	}
+
+	if w := adminRequest(server, http.MethodGet, "/admin/webhooks/2/deliveries", "admin", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown webhook, got %d", w.Code)
+	}
+	if w := adminRequest(server, http.MethodGet, "/admin/webhooks/1/deliveries?status=lost", "admin", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown status, got %d", w.Code)
+	}
+	if w := adminRequest(server, http.MethodGet, "/admin/webhooks/1/deliveries?limit=100000", "admin", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too large limit, got %d", w.Code)
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role роль клиента API
+type Role string
+
+const (
+	RoleSupport Role = "support"
+	RolePartner Role = "partner"
+	RoleAdmin   Role = "admin"
+)
+
+// ParseRole разбирает роль, неизвестные роли — ошибка
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(strings.TrimSpace(s))); r {
+	case RoleSupport, RolePartner, RoleAdmin:
+		return r, nil
+	default:
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+}
+
+// Principal аутентифицированный клиент
+type Principal struct {
+	Subject string
+	Role    Role
+}
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// APIKey статический ключ доступа
+type APIKey struct {
+	Key     string
+	Subject string
+	Role    Role
+}
+
+// ParseAPIKey разбирает ключ в формате subject:role:key
+func ParseAPIKey(s string) (APIKey, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return APIKey{}, errors.New("API key must look like subject:role:key")
+	}
+	role, err := ParseRole(parts[1])
+	if err != nil {
+		return APIKey{}, err
+	}
+	return APIKey{Subject: parts[0], Role: role, Key: parts[2]}, nil
+}
+
+// LoadRSAPublicKey читает PEM с публичным RSA-ключом
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
+
+// Config настройки аутентификации
+type Config struct {
+	APIKeys []APIKey
+	// HS256Secret общий секрет для JWT с alg=HS256
+	HS256Secret []byte
+	// RS256PublicKey публичный ключ для JWT с alg=RS256
+	RS256PublicKey *rsa.PublicKey
+	Issuer         string
+	Audience       string
+}
+
+// Authenticator проверяет API-ключи и JWT
+type Authenticator struct {
+	keys     []APIKey
+	hsSecret []byte
+	rsaKey   *rsa.PublicKey
+	parser   *jwt.Parser
+}
+
+// JWT claims: роль передаётся в claim "role"
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// New создаёт Authenticator
+func New(cfg Config) (*Authenticator, error) {
+	if len(cfg.APIKeys) == 0 && len(cfg.HS256Secret) == 0 && cfg.RS256PublicKey == nil {
+		return nil, errors.New("auth: no API keys or JWT keys configured")
+	}
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" {
+			return nil, errors.New("auth: empty API key")
+		}
+		if _, err := ParseRole(string(k.Role)); err != nil {
+			return nil, fmt.Errorf("auth: API key %q: %w", k.Subject, err)
+		}
+	}
+
+	var methods []string
+	if len(cfg.HS256Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RS256PublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &Authenticator{
+		keys:     cfg.APIKeys,
+		hsSecret: cfg.HS256Secret,
+		rsaKey:   cfg.RS256PublicKey,
+		parser:   jwt.NewParser(opts...),
+	}, nil
+}
+
+// Authenticate извлекает учётные данные из запроса:
+// заголовок X-API-Key или Authorization: Bearer <jwt>
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.checkAPIKey(key)
+	}
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return a.checkJWT(strings.TrimSpace(token))
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+func (a *Authenticator) checkAPIKey(key string) (Principal, error) {
+	// сравниваем со всеми ключами за постоянное время
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Key), []byte(key)) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: found.Subject, Role: found.Role}, nil
+}
+
+func (a *Authenticator) checkJWT(raw string) (Principal, error) {
+	if len(a.hsSecret) == 0 && a.rsaKey == nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	var c claims
+	_, err := a.parser.ParseWithClaims(raw, &c, func(t *jwt.Token) (any, error) {
+		switch t.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			return a.hsSecret, nil
+		case jwt.SigningMethodRS256.Alg():
+			return a.rsaKey, nil
+		}
+		return nil, fmt.Errorf("unexpected alg %s", t.Method.Alg())
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return Principal{Subject: c.Subject, Role: role}, nil
+}
+
+type ctxKey struct{}
+
+// WithPrincipal кладёт клиента в контекст
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext достаёт клиента из контекста
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware требует валидные учётные данные и кладёт Principal в контекст
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="order-service"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// RequireRole пропускает только клиентов с одной из перечисленных ролей.
+// Должен стоять после Middleware.
+func RequireRole(roles ...Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			for _, role := range roles {
+				if p.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+}
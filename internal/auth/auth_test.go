@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signed(t *testing.T, method jwt.SigningMethod, key any, role string, exp time.Time) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	})
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func request(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestParseAPIKey(t *testing.T) {
+	k, err := ParseAPIKey("ops:support:s3cr:et")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if k.Subject != "ops" || k.Role != RoleSupport || k.Key != "s3cr:et" {
+		t.Fatalf("unexpected key: %+v", k)
+	}
+
+	if _, err := ParseAPIKey("ops:root:key"); err == nil {
+		t.Fatalf("expected error for unknown role")
+	}
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	a, err := New(Config{APIKeys: []APIKey{{Key: "k1", Subject: "partner-a", Role: RolePartner}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Authenticate(request("X-API-Key", "k1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Subject != "partner-a" || p.Role != RolePartner {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+
+	if _, err := a.Authenticate(request("X-API-Key", "wrong")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if _, err := a.Authenticate(request("", "")); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected no credentials, got %v", err)
+	}
+}
+
+func TestAuthenticate_HS256(t *testing.T) {
+	secret := []byte("secret")
+	a, err := New(Config{HS256Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := signed(t, jwt.SigningMethodHS256, secret, "admin", time.Now().Add(time.Hour))
+	p, err := a.Authenticate(request("Authorization", "Bearer "+tok))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Role != RoleAdmin || p.Subject != "user-1" {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+
+	expired := signed(t, jwt.SigningMethodHS256, secret, "admin", time.Now().Add(-time.Hour))
+	if _, err := a.Authenticate(request("Authorization", "Bearer "+expired)); err == nil {
+		t.Fatalf("expected error for expired token")
+	}
+
+	unknownRole := signed(t, jwt.SigningMethodHS256, secret, "root", time.Now().Add(time.Hour))
+	if _, err := a.Authenticate(request("Authorization", "Bearer "+unknownRole)); err == nil {
+		t.Fatalf("expected error for unknown role")
+	}
+}
+
+func TestAuthenticate_RS256(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(Config{RS256PublicKey: &priv.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok := signed(t, jwt.SigningMethodRS256, priv, "support", time.Now().Add(time.Hour))
+	p, err := a.Authenticate(request("Authorization", "Bearer "+tok))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Role != RoleSupport {
+		t.Fatalf("unexpected role: %s", p.Role)
+	}
+
+	// HS256 с публичным ключом в качестве секрета не должен проходить
+	hs := signed(t, jwt.SigningMethodHS256, []byte("whatever"), "admin", time.Now().Add(time.Hour))
+	if _, err := a.Authenticate(request("Authorization", "Bearer "+hs)); err == nil {
+		t.Fatalf("expected error for unexpected alg")
+	}
+}
+
+func TestMiddleware_RequireRole(t *testing.T) {
+	a, err := New(Config{APIKeys: []APIKey{
+		{Key: "admin-key", Subject: "ops", Role: RoleAdmin},
+		{Key: "partner-key", Subject: "p", Role: RolePartner},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := a.Middleware(RequireRole(RoleAdmin)(ok))
+
+	tests := []struct {
+		key  string
+		code int
+	}{
+		{"", http.StatusUnauthorized},
+		{"partner-key", http.StatusForbidden},
+		{"admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request("X-API-Key", tt.key))
+		if w.Code != tt.code {
+			t.Fatalf("key %q: expected %d, got %d", tt.key, tt.code, w.Code)
+		}
+	}
+}
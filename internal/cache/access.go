@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// decayPeriod во сколько раз больше предела ключей обращений между делениями
+// счётчиков: обход всех счётчиков амортизируется на decayPeriod*max обращений
+const decayPeriod = 8
+
+// accessCounter приблизительные счётчики обращений одного шарда.
+//
+// Get не берёт блокировок: счётчик уже известного ключа увеличивается атомарно,
+// новый ключ добавляется, только пока есть место. Каждые decayPeriod*max
+// обращений счётчики делятся пополам, обнулившиеся удаляются и освобождают место
+// новым ключам, так что старая популярность со временем забывается.
+type accessCounter[K comparable] struct {
+	hits  sync.Map // K -> *atomic.Uint64
+	keys  atomic.Int64
+	seen  atomic.Uint64
+	max   int
+	every uint64
+
+	// только один обход за раз; опоздавший просто пропускает деление
+	decayMu sync.Mutex
+}
+
+func newAccessCounter[K comparable](maxKeys int) *accessCounter[K] {
+	return &accessCounter[K]{max: maxKeys, every: uint64(decayPeriod * maxKeys)}
+}
+
+func (a *accessCounter[K]) record(key K) {
+	if p, ok := a.hits.Load(key); ok {
+		p.(*atomic.Uint64).Add(1)
+	} else if a.keys.Load() < int64(a.max) {
+		p, loaded := a.hits.LoadOrStore(key, new(atomic.Uint64))
+		if !loaded {
+			a.keys.Add(1)
+		}
+		p.(*atomic.Uint64).Add(1)
+	}
+	if a.seen.Add(1)%a.every == 0 {
+		a.decay()
+	}
+}
+
+// decay делит счётчики пополам. Обращение, пришедшее одновременно с удалением
+// ключа, может потеряться: счётчики и так приблизительные.
+func (a *accessCounter[K]) decay() {
+	if !a.decayMu.TryLock() {
+		return
+	}
+	defer a.decayMu.Unlock()
+	a.hits.Range(func(k, v any) bool {
+		p := v.(*atomic.Uint64)
+		for {
+			n := p.Load()
+			if !p.CompareAndSwap(n, n/2) {
+				continue
+			}
+			if n/2 == 0 && a.hits.CompareAndDelete(k, p) {
+				a.keys.Add(-1)
+			}
+			return true
+		}
+	})
+}
+
+func (a *accessCounter[K]) appendTo(out []Access[K]) []Access[K] {
+	a.hits.Range(func(k, v any) bool {
+		if n := v.(*atomic.Uint64).Load(); n > 0 {
+			out = append(out, Access[K]{Key: k.(K), Hits: n})
+		}
+		return true
+	})
+	return out
+}
@@ -3,29 +3,112 @@ package cache
 import (
 	"context"
 	"errors"
+	"hash/maphash"
+	"sort"
 	"sync"
 	"time"
 )
 
-type Cache struct {
-	mu       sync.RWMutex
-	items    map[string]Item
+// DefaultShards число шардов по умолчанию
+const DefaultShards = 32
+
+// Cache кэш с TTL. Тип значений задаётся при создании, так что ошибиться
+// с ним можно только на этапе компиляции.
+//
+// Ключи разбиты по шардам со своими блокировками: запись и чистка одного шарда
+// не задерживают чтение остальных.
+type Cache[K comparable, V any] struct {
+	shards   []*shard[K, V]
+	seed     maphash.Seed
 	ttl      time.Duration
 	cleanup  time.Duration
+	version  func(V) int64
 	cancelGC context.CancelFunc
 }
 
-type Item struct {
-	Value      interface{}
+type shard[K comparable, V any] struct {
+	mu    sync.RWMutex
+	items map[K]Item[V]
+	// отметки Invalidate; nil, пока их не было
+	tombs map[K]tombstone
+
+	// счётчики обращений для прогрева популярными заказами; nil — не считаем
+	access *accessCounter[K]
+}
+
+type options struct {
+	shards    int
+	trackKeys int
+}
+
+// Option настраивает Cache
+type Option func(*options)
+
+// WithShards на сколько шардов делить ключи; 1 — одна блокировка на весь кэш
+func WithShards(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.shards = n
+		}
+	}
+}
+
+// WithAccessTracking считает обращения к ключам, включая промахи, примерно для
+// maxKeys ключей: предел делится между шардами поровну. Новые ключи в заполненный
+// шард не попадают, пока периодическое деление счётчиков пополам не освободит место,
+// так что старая популярность со временем забывается.
+func WithAccessTracking(maxKeys int) Option {
+	return func(o *options) { o.trackKeys = maxKeys }
+}
+
+// tombstoneTTL сколько помнить Invalidate: дольше любого чтения из БД,
+// результат которого может прийти в Set после уведомления
+const tombstoneTTL = time.Minute
+
+// tombstone до expires не принимаются значения версии меньше version
+type tombstone struct {
+	version int64
+	expires int64
+}
+
+type Item[V any] struct {
+	Value      V
 	Created    time.Time
 	Expiration int64
 }
 
-func New(ttl, cleanupInterval time.Duration) *Cache {
-	c := &Cache{
-		items:   make(map[string]Item),
+func (it Item[V]) expired(now int64) bool {
+	return it.Expiration > 0 && now > it.Expiration
+}
+
+func New[K comparable, V any](ttl, cleanupInterval time.Duration, opts ...Option) *Cache[K, V] {
+	return newCache[K, V](ttl, cleanupInterval, nil, opts...)
+}
+
+// NewVersioned как New, но со сравнением версий значений: Set не заменяет запись
+// более новой версии на старую, а Invalidate оставляет отметку о версии.
+func NewVersioned[K comparable, V any](ttl, cleanupInterval time.Duration, version func(V) int64, opts ...Option) *Cache[K, V] {
+	return newCache[K, V](ttl, cleanupInterval, version, opts...)
+}
+
+func newCache[K comparable, V any](ttl, cleanupInterval time.Duration, version func(V) int64, opts ...Option) *Cache[K, V] {
+	o := options{shards: DefaultShards}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &Cache[K, V]{
+		shards:  make([]*shard[K, V], o.shards),
+		seed:    maphash.MakeSeed(),
 		ttl:     ttl,
 		cleanup: cleanupInterval,
+		version: version,
+	}
+	for i := range c.shards {
+		s := &shard[K, V]{items: make(map[K]Item[V])}
+		if o.trackKeys > 0 {
+			s.access = newAccessCounter[K]((o.trackKeys + o.shards - 1) / o.shards)
+		}
+		c.shards[i] = s
 	}
 
 	if cleanupInterval > 0 {
@@ -36,64 +119,182 @@ func New(ttl, cleanupInterval time.Duration) *Cache {
 	return c
 }
 
-func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+func (c *Cache[K, V]) shardFor(key K) *shard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
 	if ttl == 0 {
 		ttl = c.ttl
 	}
+	now := time.Now()
 	exp := int64(0)
 	if ttl > 0 {
-		exp = time.Now().Add(ttl).UnixNano()
+		exp = now.Add(ttl).UnixNano()
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items[key] = Item{Value: value, Created: time.Now(), Expiration: exp}
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.version != nil && c.outdated(s, key, value, now.UnixNano()) {
+		return
+	}
+	s.items[key] = Item[V]{Value: value, Created: now, Expiration: exp}
 }
 
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	item, ok := c.items[key]
-	if !ok {
-		return nil, false
+// outdated старее ли value живой записи или отметки Invalidate. Вызывается под s.mu.
+func (c *Cache[K, V]) outdated(s *shard[K, V], key K, value V, now int64) bool {
+	v := c.version(value)
+	if cur, ok := s.items[key]; ok && !cur.expired(now) && c.version(cur.Value) > v {
+		return true
 	}
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
-		return nil, false
+	t, ok := s.tombs[key]
+	return ok && now <= t.expires && v < t.version
+}
+
+// Invalidate убирает запись версии меньше version и tombstoneTTL не принимает
+// значения такой версии: чтение из БД, начатое до сохранения новой версии, может
+// закончиться Set'ом уже после уведомления о ней. Запись той же версии или новее
+// остаётся. Без версий (New) и с version 0 (версия неизвестна) — просто удаление.
+func (c *Cache[K, V]) Invalidate(key K, version int64) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.version == nil || version == 0 {
+		delete(s.items, key)
+		return
+	}
+	if cur, ok := s.items[key]; ok && c.version(cur.Value) >= version {
+		return
+	}
+	delete(s.items, key)
+	if s.tombs == nil {
+		s.tombs = make(map[K]tombstone)
+	}
+	t := s.tombs[key]
+	s.tombs[key] = tombstone{
+		version: max(t.version, version),
+		expires: time.Now().Add(tombstoneTTL).UnixNano(),
+	}
+}
+
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	s := c.shardFor(key)
+	if s.access != nil {
+		s.access.record(key)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[key]
+	if !ok || item.expired(time.Now().UnixNano()) {
+		var zero V
+		return zero, false
 	}
 	return item.Value, true
 }
 
-func (c *Cache) Delete(key string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if _, ok := c.items[key]; !ok {
+// Access число обращений к ключу
+type Access[K comparable] struct {
+	Key  K      `json:"key"`
+	Hits uint64 `json:"hits"`
+}
+
+// TopAccessed до n самых запрашиваемых ключей, по убыванию обращений.
+// Без WithAccessTracking пусто.
+func (c *Cache[K, V]) TopAccessed(n int) []Access[K] {
+	var out []Access[K]
+	for _, s := range c.shards {
+		if s.access != nil {
+			out = s.access.appendTo(out)
+		}
+	}
+
+	// при равенстве порядок произвольный: ключи K не обязаны сравниваться
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Hits > out[j].Hits })
+	if n >= 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+func (c *Cache[K, V]) Delete(key K) error {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[key]; !ok {
 		return errors.New("key not found")
 	}
-	delete(c.items, key)
+	delete(s.items, key)
 	return nil
 }
 
-func (c *Cache) gc(ctx context.Context) {
-	ticker := time.NewTicker(c.cleanup)
+// Clear удаляет все записи; счётчики обращений не трогает
+func (c *Cache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		clear(s.items)
+		s.mu.Unlock()
+	}
+}
+
+// gc чистит по одному шарду за тик, так что весь кэш обходится
+// примерно за cleanupInterval
+func (c *Cache[K, V]) gc(ctx context.Context) {
+	ticker := time.NewTicker(max(c.cleanup/time.Duration(len(c.shards)), time.Millisecond))
 	defer ticker.Stop()
 
+	next := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			c.mu.Lock()
-			for k, v := range c.items {
-				if v.Expiration > 0 && time.Now().UnixNano() > v.Expiration {
-					delete(c.items, k)
-				}
-			}
-			c.mu.Unlock()
+			c.shards[next].sweep()
+			next = (next + 1) % len(c.shards)
+		}
+	}
+}
+
+// sweep удаляет истёкшие записи и отметки шарда. Поиск идёт под блокировкой
+// на чтение, так что Get не ждёт обхода; на запись шард блокируется только для удаления.
+func (s *shard[K, V]) sweep() {
+	now := time.Now().UnixNano()
+	var expired, tombs []K
+	s.mu.RLock()
+	for k, v := range s.items {
+		if v.expired(now) {
+			expired = append(expired, k)
+		}
+	}
+	for k, t := range s.tombs {
+		if now > t.expires {
+			tombs = append(tombs, k)
+		}
+	}
+	s.mu.RUnlock()
+	if len(expired) == 0 && len(tombs) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	for _, k := range expired {
+		// за время без блокировки запись могли обновить
+		if v, ok := s.items[k]; ok && v.expired(now) {
+			delete(s.items, k)
+		}
+	}
+	for _, k := range tombs {
+		if t, ok := s.tombs[k]; ok && now > t.expires {
+			delete(s.tombs, k)
 		}
 	}
+	s.mu.Unlock()
 }
 
-func (c *Cache) StopGC() {
+func (c *Cache[K, V]) StopGC() {
 	if c.cancelGC != nil {
 		c.cancelGC()
 	}
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"bytes"
+	"sync"
 	"testing"
 	"time"
 )
@@ -8,7 +10,7 @@ import (
 /************* BASIC SET / GET *************/
 
 func TestCache_SetGet(t *testing.T) {
-	c := New(time.Minute, 0)
+	c := New[string, string](time.Minute, 0)
 
 	c.Set("key", "value", 0)
 
@@ -17,7 +19,7 @@ func TestCache_SetGet(t *testing.T) {
 		t.Fatalf("expected key to exist")
 	}
 
-	if v.(string) != "value" {
+	if v != "value" {
 		t.Fatalf("unexpected value: %v", v)
 	}
 }
@@ -25,7 +27,7 @@ func TestCache_SetGet(t *testing.T) {
 /************* TTL EXPIRATION *************/
 
 func TestCache_Expiration(t *testing.T) {
-	c := New(50*time.Millisecond, 0)
+	c := New[string, string](50*time.Millisecond, 0)
 
 	c.Set("key", "value", 50*time.Millisecond)
 
@@ -39,7 +41,7 @@ func TestCache_Expiration(t *testing.T) {
 /************* DELETE *************/
 
 func TestCache_Delete(t *testing.T) {
-	c := New(time.Minute, 0)
+	c := New[string, string](time.Minute, 0)
 
 	c.Set("key", "value", 0)
 
@@ -53,17 +55,105 @@ func TestCache_Delete(t *testing.T) {
 }
 
 func TestCache_Delete_NotFound(t *testing.T) {
-	c := New(time.Minute, 0)
+	c := New[string, string](time.Minute, 0)
 
 	if err := c.Delete("missing"); err == nil {
 		t.Fatalf("expected error on delete missing key")
 	}
 }
 
+func TestCache_Clear(t *testing.T) {
+	c := New[int, string](time.Minute, 0, WithShards(4))
+	for i := 0; i < 10; i++ {
+		c.Set(i, "v", 0)
+	}
+	c.Clear()
+	if n := c.size(); n != 0 {
+		t.Fatalf("expected empty cache, got %d entries", n)
+	}
+}
+
+/************* VERSIONS *************/
+
+type versioned struct {
+	V    int64
+	Name string
+}
+
+func newVersioned() *Cache[string, versioned] {
+	return NewVersioned[string](time.Minute, 0, func(v versioned) int64 { return v.V })
+}
+
+func TestCache_SetKeepsNewerVersion(t *testing.T) {
+	c := newVersioned()
+	c.Set("k", versioned{V: 2, Name: "new"}, 0)
+	c.Set("k", versioned{V: 1, Name: "old"}, 0)
+	if v, _ := c.Get("k"); v.Name != "new" {
+		t.Fatalf("older version must not replace newer, got %+v", v)
+	}
+	c.Set("k", versioned{V: 2, Name: "same"}, 0)
+	if v, _ := c.Get("k"); v.Name != "same" {
+		t.Fatalf("same version must be replaced, got %+v", v)
+	}
+}
+
+func TestCache_InvalidateBlocksStaleSet(t *testing.T) {
+	c := newVersioned()
+	c.Set("k", versioned{V: 1}, 0)
+
+	// уведомление о версии 2 пришло раньше, чем чтение версии 1 из БД дошло до Set
+	c.Invalidate("k", 2)
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("older entry must be invalidated")
+	}
+	c.Set("k", versioned{V: 1}, 0)
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("stale set after invalidate must be rejected")
+	}
+	c.Set("k", versioned{V: 2}, 0)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatalf("invalidated version itself must be accepted")
+	}
+
+	// запись той же версии уведомление не трогает
+	c.Invalidate("k", 2)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatalf("entry of the notified version must stay")
+	}
+}
+
+func TestCache_InvalidateUnknownVersion(t *testing.T) {
+	c := newVersioned()
+	c.Set("k", versioned{V: 5}, 0)
+	c.Invalidate("k", 0)
+	if _, ok := c.Get("k"); ok {
+		t.Fatalf("version 0 must delete unconditionally")
+	}
+	c.Set("k", versioned{V: 1}, 0)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatalf("version 0 must not leave a tombstone")
+	}
+}
+
+func TestCache_SweepRemovesTombstones(t *testing.T) {
+	c := NewVersioned[string](time.Minute, 0, func(v versioned) int64 { return v.V }, WithShards(1))
+	c.Invalidate("k", 2)
+	s := c.shards[0]
+	s.tombs["k"] = tombstone{version: 2, expires: time.Now().Add(-time.Second).UnixNano()}
+	s.sweep()
+	if len(s.tombs) != 0 {
+		t.Fatalf("expired tombstone must be swept")
+	}
+	c.Set("k", versioned{V: 1}, 0)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatalf("set must be accepted after tombstone expired")
+	}
+}
+
 /************* GC *************/
 
 func TestCache_GC_RemovesExpired(t *testing.T) {
-	c := New(20*time.Millisecond, 10*time.Millisecond)
+	c := New[string, string](20*time.Millisecond, 10*time.Millisecond)
 	defer c.StopGC()
 
 	c.Set("key", "value", 20*time.Millisecond)
@@ -75,8 +165,196 @@ func TestCache_GC_RemovesExpired(t *testing.T) {
 	}
 }
 
+func TestCache_GC_SweepsAllShards(t *testing.T) {
+	c := New[int, string](time.Minute, 20*time.Millisecond, WithShards(4))
+	defer c.StopGC()
+
+	for i := 0; i < 100; i++ {
+		c.Set(i, "v", 10*time.Millisecond)
+	}
+	c.Set(-1, "v", time.Hour)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if n := c.size(); n != 1 {
+		t.Fatalf("expected only the live entry to remain, got %d", n)
+	}
+}
+
+func TestCache_ConcurrentAccess(t *testing.T) {
+	c := New[int, int](time.Minute, time.Millisecond, WithAccessTracking(64))
+	defer c.StopGC()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := (g*1000 + i) % 200
+				c.Set(k, i, time.Millisecond)
+				c.Get(k)
+				if i%100 == 0 {
+					c.TopAccessed(10)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// item запись в обход проверки TTL
+func (c *Cache[K, V]) item(key K) Item[V] {
+	s := c.shardFor(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items[key]
+}
+
+// size число записей, включая истёкшие, но ещё не вычищенные
+func (c *Cache[K, V]) size() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.items)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
 func TestCache_StopGC(t *testing.T) {
-	c := New(time.Millisecond, 10*time.Millisecond)
+	c := New[string, string](time.Millisecond, 10*time.Millisecond)
 
 	c.StopGC() // просто проверяем, что не паникует
 }
+
+/************* ACCESS TRACKING *************/
+
+func TestCache_TopAccessed(t *testing.T) {
+	c := New[string, int](time.Minute, 0, WithShards(1), WithAccessTracking(10))
+
+	c.Set("a", 1, 0)
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+	}
+	// промахи тоже считаются: заказ запрашивают, даже если его нет в кэше
+	c.Get("b")
+	c.Get("b")
+	c.Get("c")
+
+	top := c.TopAccessed(2)
+	if len(top) != 2 || top[0] != (Access[string]{Key: "a", Hits: 3}) || top[1] != (Access[string]{Key: "b", Hits: 2}) {
+		t.Fatalf("unexpected top: %+v", top)
+	}
+}
+
+func TestCache_AccessTrackingBounded(t *testing.T) {
+	c := New[string, string](time.Minute, 0, WithShards(1), WithAccessTracking(3))
+
+	for i := 0; i < 4; i++ {
+		c.Get("hot")
+	}
+	for _, k := range []string{"x", "y", "z"} {
+		c.Get(k)
+	}
+
+	// шард заполнен: z не считается
+	if top := c.TopAccessed(-1); len(top) != 3 || top[0] != (Access[string]{Key: "hot", Hits: 4}) {
+		t.Fatalf("unexpected counters before decay: %+v", top)
+	}
+
+	// на 24-м обращении (decayPeriod*3) счётчики делятся пополам, единичные забыты
+	for i := 0; i < 17; i++ {
+		c.Get("hot")
+	}
+	top := c.TopAccessed(-1)
+	if len(top) != 1 || top[0] != (Access[string]{Key: "hot", Hits: 10}) {
+		t.Fatalf("unexpected counters after decay: %+v", top)
+	}
+
+	c.Get("z")
+	if top := c.TopAccessed(-1); len(top) != 2 {
+		t.Fatalf("decay must free room for new keys: %+v", top)
+	}
+}
+
+func TestCache_NoAccessTracking(t *testing.T) {
+	c := New[string, string](time.Minute, 0)
+	c.Get("a")
+	if top := c.TopAccessed(10); len(top) != 0 {
+		t.Fatalf("tracking must be off by default, got %+v", top)
+	}
+}
+
+/************* SNAPSHOT *************/
+
+type snapValue struct {
+	Name  string
+	Items []int
+}
+
+func TestCache_SnapshotRoundTrip(t *testing.T) {
+	src := New[string, snapValue](time.Hour, 0)
+	src.Set("order", snapValue{Name: "a", Items: []int{1, 2}}, 0)
+	src.Set("forever", snapValue{Name: "old"}, -1)
+	src.Set("short", snapValue{}, 100*time.Millisecond)
+	src.Set("expired", snapValue{}, time.Nanosecond)
+
+	var buf bytes.Buffer
+	n, err := src.SaveSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 live entries saved, got %d", n)
+	}
+
+	// пока процесс лежал, short успел истечь
+	time.Sleep(150 * time.Millisecond)
+
+	dst := New[string, snapValue](time.Hour, 0)
+	dst.Set("forever", snapValue{Name: "fresh"}, 0)
+	n, err = dst.LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected only order restored, got %d", n)
+	}
+	v, ok := dst.Get("order")
+	if !ok || v.Name != "a" || len(v.Items) != 2 {
+		t.Fatalf("unexpected restored value %#v", v)
+	}
+	if v, _ := dst.Get("forever"); v.Name != "fresh" {
+		t.Fatalf("existing entry must win over snapshot, got %v", v)
+	}
+	if _, ok := dst.Get("short"); ok {
+		t.Fatalf("entry expired during downtime must not be restored")
+	}
+
+	left := time.Duration(dst.item("order").Expiration - time.Now().UnixNano())
+	if left > time.Hour-150*time.Millisecond || left < 50*time.Minute {
+		t.Fatalf("remaining TTL not preserved: %v", left)
+	}
+}
+
+func TestCache_LoadSnapshotGarbage(t *testing.T) {
+	c := New[string, snapValue](time.Hour, 0)
+	if _, err := c.LoadSnapshot(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Fatal("expected error for garbage input")
+	}
+}
+
+/************* TYPED VALUES *************/
+
+func TestCache_MissReturnsZero(t *testing.T) {
+	c := New[int, *snapValue](time.Minute, 0)
+	c.Set(1, &snapValue{Name: "a"}, 0)
+
+	if v, ok := c.Get(1); !ok || v.Name != "a" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if v, ok := c.Get(2); ok || v != nil {
+		t.Fatalf("miss must return zero value, got %v", v)
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis кэш в Redis, общий для всех реплик. Значения хранятся в gob.
+//
+// Интерфейс тот же, что у Cache, без ошибок: недоступный Redis — это промах,
+// а заказ тогда читается из БД. О пропаже и возвращении Redis пишется в лог
+// один раз, а не на каждый запрос.
+type Redis[V any] struct {
+	client  redis.UniversalClient
+	ttl     time.Duration
+	prefix  string
+	timeout time.Duration
+	failing atomic.Bool
+	// version см. NewVersionedRedis; nil — значения лежат строками без версий
+	version func(V) int64
+}
+
+// RedisOption настраивает Redis
+type RedisOption func(*redisOptions)
+
+type redisOptions struct {
+	prefix  string
+	timeout time.Duration
+}
+
+// WithKeyPrefix добавляется ко всем ключам, чтобы делить Redis с другими сервисами
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) { o.prefix = prefix }
+}
+
+// WithOpTimeout предел одной операции: медленный Redis не должен тормозить API сильнее БД
+func WithOpTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// NewRedis ttl — срок записей по умолчанию, как в New; 0 и меньше — без срока
+func NewRedis[V any](client redis.UniversalClient, ttl time.Duration, opts ...RedisOption) *Redis[V] {
+	o := redisOptions{timeout: 100 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Redis[V]{client: client, ttl: ttl, prefix: o.prefix, timeout: o.timeout}
+}
+
+// NewVersionedRedis как NewRedis, но Set не заменяет в Redis более новую версию
+// значения: запрос, прочитавший заказ из БД до сохранения, не затрёт заказ, который
+// consumer положил в Redis после. Значение хранится хэшем {v: версия, d: gob},
+// сравнение и запись выполняет Lua-скрипт атомарно.
+func NewVersionedRedis[V any](client redis.UniversalClient, ttl time.Duration, version func(V) int64, opts ...RedisOption) *Redis[V] {
+	r := NewRedis[V](client, ttl, opts...)
+	r.version = version
+	return r
+}
+
+// setVersioned KEYS[1] — ключ; ARGV — версия (20 цифр, чтобы сравнивать строками
+// без потери точности в числах Lua), gob и срок в мс (0 — без срока).
+// Значение старого формата (строка) перезаписывается.
+var setVersioned = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok ~= 'hash' then
+	redis.call('DEL', KEYS[1])
+end
+local cur = redis.call('HGET', KEYS[1], 'v')
+if cur and cur > ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'v', ARGV[1], 'd', ARGV[2])
+if ARGV[3] == '0' then
+	redis.call('PERSIST', KEYS[1])
+else
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+func (r *Redis[V]) Set(key string, value V, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = r.ttl
+	}
+	// в go-redis 0 — без срока, а отрицательный срок значит KEEPTTL
+	ttl = max(ttl, 0)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		slog.Error("redis cache encode failed", "key", key, "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if r.version == nil {
+		r.track(r.client.Set(ctx, r.prefix+key, buf.Bytes(), ttl).Err())
+		return
+	}
+	ms := int64(0)
+	if ttl > 0 {
+		ms = max(ttl.Milliseconds(), 1)
+	}
+	// отрицательная версия (нулевое время) меньше любой другой
+	version := fmt.Sprintf("%020d", max(r.version(value), 0))
+	r.track(setVersioned.Run(ctx, r.client, []string{r.prefix + key}, version, buf.Bytes(), ms).Err())
+}
+
+func (r *Redis[V]) Get(key string) (V, bool) {
+	var v V
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	var data []byte
+	var err error
+	if r.version == nil {
+		data, err = r.client.Get(ctx, r.prefix+key).Bytes()
+	} else {
+		data, err = r.client.HGet(ctx, r.prefix+key, "d").Bytes()
+	}
+	// WRONGTYPE — значение другого формата: промах, Set его перезапишет
+	if errors.Is(err, redis.Nil) || (err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")) {
+		r.track(nil)
+		return v, false
+	}
+	if r.track(err) != nil {
+		return v, false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		// запись старого формата или чужая: считаем промахом, Set её перезапишет
+		slog.Warn("redis cache decode failed", "key", key, "err", err)
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+// Delete удаляет ключ; отсутствие ключа не ошибка
+func (r *Redis[V]) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	return r.track(r.client.Del(ctx, r.prefix+key).Err())
+}
+
+// track пишет в лог смену доступности Redis
+func (r *Redis[V]) track(err error) error {
+	if err != nil {
+		if !r.failing.Swap(true) {
+			slog.Warn("redis cache unavailable, falling back to DB", "err", err)
+		}
+		return err
+	}
+	if r.failing.Swap(false) {
+		slog.Info("redis cache available again")
+	}
+	return nil
+}
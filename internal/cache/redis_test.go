@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedis_SetGet(t *testing.T) {
+	mr, client := newTestRedis(t)
+	c := NewRedis[snapValue](client, time.Minute, WithKeyPrefix("orders:"))
+
+	c.Set("a", snapValue{Name: "a", Items: []int{1, 2}}, 0)
+	c.Set("forever", snapValue{Name: "f"}, -1)
+
+	v, ok := c.Get("a")
+	if !ok || v.Name != "a" || len(v.Items) != 2 {
+		t.Fatalf("unexpected value %#v", v)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Fatalf("expected miss")
+	}
+	if ttl := mr.TTL("orders:a"); ttl != time.Minute {
+		t.Fatalf("expected default TTL on prefixed key, got %v", ttl)
+	}
+	if ttl := mr.TTL("orders:forever"); ttl != 0 {
+		t.Fatalf("negative ttl must mean no expiry, got %v", ttl)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected key to expire in redis")
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Fatalf("expected key without TTL to stay")
+	}
+}
+
+func TestRedis_Unavailable(t *testing.T) {
+	mr, client := newTestRedis(t)
+	c := NewRedis[snapValue](client, time.Minute, WithOpTimeout(50*time.Millisecond))
+	c.Set("a", snapValue{Name: "a"}, 0)
+
+	mr.Close()
+	// недоступный Redis — промах, а не паника или зависание
+	c.Set("b", snapValue{Name: "b"}, 0)
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("expected miss while redis is down")
+	}
+	if !c.failing.Load() {
+		t.Fatalf("expected redis to be marked failing")
+	}
+}
+
+func TestRedis_CorruptValue(t *testing.T) {
+	mr, client := newTestRedis(t)
+	c := NewRedis[snapValue](client, time.Minute)
+	mr.Set("a", "not gob")
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("undecodable value must be a miss")
+	}
+}
+
+func TestRedis_Versioned(t *testing.T) {
+	mr, client := newTestRedis(t)
+	c := NewVersionedRedis[versioned](client, time.Minute, func(v versioned) int64 { return v.V })
+
+	c.Set("k", versioned{V: 2, Name: "consumer"}, 0)
+	// API прочитал заказ из БД до сохранения и кладёт его позже
+	c.Set("k", versioned{V: 1, Name: "stale read"}, 0)
+	if v, ok := c.Get("k"); !ok || v.Name != "consumer" {
+		t.Fatalf("older version must not replace newer, got %+v", v)
+	}
+	c.Set("k", versioned{V: 3, Name: "newer"}, -1)
+	if v, ok := c.Get("k"); !ok || v.Name != "newer" {
+		t.Fatalf("newer version must replace older, got %+v", v)
+	}
+	if ttl := mr.TTL("k"); ttl != 0 {
+		t.Fatalf("negative ttl must mean no expiry, got %v", ttl)
+	}
+	c.Set("exp", versioned{V: 1}, 0)
+	if ttl := mr.TTL("exp"); ttl != time.Minute {
+		t.Fatalf("expected default TTL, got %v", ttl)
+	}
+
+	// значение из NewRedis: промах без пометки Redis недоступным, Set его заменяет
+	mr.Set("old", "plain")
+	if _, ok := c.Get("old"); ok || c.failing.Load() {
+		t.Fatalf("value of the old format must be a plain miss")
+	}
+	c.Set("old", versioned{V: 1, Name: "new format"}, 0)
+	if v, ok := c.Get("old"); !ok || v.Name != "new format" {
+		t.Fatalf("Set must replace the old format, got %+v", v)
+	}
+}
+
+func TestTiered(t *testing.T) {
+	mr, client := newTestRedis(t)
+	remote := NewRedis[snapValue](client, time.Hour)
+	local := New[string, snapValue](time.Hour, 0)
+	c := NewTiered(local, remote, 30*time.Second)
+
+	c.Set("a", snapValue{Name: "a"}, 0)
+	if v, ok := local.Get("a"); !ok || v.Name != "a" {
+		t.Fatalf("Set must write the local tier")
+	}
+	if left := time.Duration(local.item("a").Expiration - time.Now().UnixNano()); left > 30*time.Second {
+		t.Fatalf("local TTL must be capped by localTTL, got %v", left)
+	}
+	if mr.TTL("a") != time.Hour {
+		t.Fatalf("remote must keep the full TTL, got %v", mr.TTL("a"))
+	}
+
+	// другая реплика: локально пусто, заказ берётся из Redis и оседает в процессе
+	other := New[string, snapValue](time.Hour, 0)
+	c2 := NewTiered(other, remote, 30*time.Second)
+	if v, ok := c2.Get("a"); !ok || v.Name != "a" {
+		t.Fatalf("expected value from redis, got %v", v)
+	}
+	if _, ok := other.Get("a"); !ok {
+		t.Fatalf("redis hit must populate the local tier")
+	}
+
+	// локальный уровень отвечает и без Redis
+	mr.Close()
+	if _, ok := c2.Get("a"); !ok {
+		t.Fatalf("expected local hit while redis is down")
+	}
+
+	if err := c2.Delete("a"); err == nil {
+		t.Fatalf("expected redis error on delete while down")
+	}
+	if _, ok := other.Get("a"); ok {
+		t.Fatalf("Delete must clear the local tier even if redis is down")
+	}
+}
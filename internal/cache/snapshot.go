@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// snapshotVersion меняется при несовместимом изменении формата
+const snapshotVersion = 1
+
+type snapshotHeader struct {
+	Version int
+	SavedAt time.Time
+}
+
+// snapshotEntry запись кэша; TTL — сколько ей оставалось жить на момент SavedAt, 0 — вечно
+type snapshotEntry[K comparable, V any] struct {
+	Key     K
+	Value   V
+	Created time.Time
+	TTL     time.Duration
+}
+
+// SaveSnapshot пишет живые записи кэша с оставшимся TTL в w (gob).
+// Шарды сохраняются по очереди, каждый блокируется только на время копирования.
+func (c *Cache[K, V]) SaveSnapshot(w io.Writer) (int, error) {
+	now := time.Now()
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, SavedAt: now}); err != nil {
+		return 0, err
+	}
+	saved := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		entries := make([]snapshotEntry[K, V], 0, len(s.items))
+		for k, it := range s.items {
+			e := snapshotEntry[K, V]{Key: k, Value: it.Value, Created: it.Created}
+			if it.Expiration > 0 {
+				if e.TTL = time.Duration(it.Expiration - now.UnixNano()); e.TTL <= 0 {
+					continue
+				}
+			}
+			entries = append(entries, e)
+		}
+		s.mu.RUnlock()
+
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return saved, fmt.Errorf("snapshot key %v: %w", entries[i].Key, err)
+			}
+			saved++
+		}
+	}
+	return saved, nil
+}
+
+// LoadSnapshot восстанавливает записи из SaveSnapshot. Время, пока процесс
+// не работал, вычитается из TTL: истёкшие за это время записи пропускаются.
+// Уже существующие ключи не перезаписываются, они свежее снапшота.
+func (c *Cache[K, V]) LoadSnapshot(r io.Reader) (int, error) {
+	dec := gob.NewDecoder(r)
+	var h snapshotHeader
+	if err := dec.Decode(&h); err != nil {
+		return 0, fmt.Errorf("snapshot header: %w", err)
+	}
+	if h.Version != snapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", h.Version)
+	}
+
+	now := time.Now()
+	downtime := max(now.Sub(h.SavedAt), 0)
+	loaded := 0
+	for {
+		var e snapshotEntry[K, V]
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return loaded, nil
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("snapshot entry %d: %w", loaded+1, err)
+		}
+
+		exp := int64(0)
+		if e.TTL > 0 {
+			left := e.TTL - downtime
+			if left <= 0 {
+				continue
+			}
+			exp = now.Add(left).UnixNano()
+		}
+		s := c.shardFor(e.Key)
+		s.mu.Lock()
+		if _, exists := s.items[e.Key]; !exists {
+			s.items[e.Key] = Item[V]{Value: e.Value, Created: e.Created, Expiration: exp}
+			loaded++
+		}
+		s.mu.Unlock()
+	}
+}
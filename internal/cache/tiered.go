@@ -0,0 +1,45 @@
+package cache
+
+import "time"
+
+// Tiered двухуровневый кэш: быстрый кэш процесса перед общим Redis.
+// Локальные записи живут не дольше localTTL, этим ограничено расхождение
+// между репликами, когда заказ обновила другая реплика.
+type Tiered[V any] struct {
+	local    *Cache[string, V]
+	remote   *Redis[V]
+	localTTL time.Duration
+}
+
+func NewTiered[V any](local *Cache[string, V], remote *Redis[V], localTTL time.Duration) *Tiered[V] {
+	return &Tiered[V]{local: local, remote: remote, localTTL: localTTL}
+}
+
+// Get сначала смотрит в процессе, затем в Redis; найденное в Redis
+// запоминается локально
+func (t *Tiered[V]) Get(key string) (V, bool) {
+	if v, ok := t.local.Get(key); ok {
+		return v, true
+	}
+	v, ok := t.remote.Get(key)
+	if ok {
+		t.local.Set(key, v, t.localTTL)
+	}
+	return v, ok
+}
+
+// Set пишет в оба уровня; ttl действует в Redis, локально — не дольше localTTL
+func (t *Tiered[V]) Set(key string, value V, ttl time.Duration) {
+	t.remote.Set(key, value, ttl)
+	local := t.localTTL
+	if ttl > 0 {
+		local = min(local, ttl)
+	}
+	t.local.Set(key, value, local)
+}
+
+// Delete удаляет ключ с обоих уровней
+func (t *Tiered[V]) Delete(key string) error {
+	_ = t.local.Delete(key)
+	return t.remote.Delete(key)
+}
@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// magicByte первый байт wire-формата Confluent: 0x00, затем id схемы
+// (4 байта big-endian) и avro binary
+const magicByte = 0x00
+
+var ErrUnknownSchema = errors.New("unknown avro schema")
+
+// SchemaRegistry источник avro-схем по id
+type SchemaRegistry interface {
+	Schema(id int) (string, error)
+}
+
+// FileRegistry локальная замена schema registry: схема с id N лежит
+// в файле <dir>/N.avsc
+type FileRegistry struct {
+	dir string
+}
+
+// NewFileRegistry создаёт реестр схем из каталога
+func NewFileRegistry(dir string) *FileRegistry {
+	return &FileRegistry{dir: dir}
+}
+
+// Schema читает схему с диска
+func (r *FileRegistry) Schema(id int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, strconv.Itoa(id)+".avsc"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: id %d", ErrUnknownSchema, id)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Avro декодер сообщений в wire-формате Confluent
+type Avro struct {
+	schemas SchemaRegistry
+
+	mu     sync.Mutex
+	codecs map[int]*goavro.Codec
+}
+
+// NewAvro создаёт декодер; скомпилированные схемы кэшируются по id
+func NewAvro(schemas SchemaRegistry) *Avro {
+	return &Avro{schemas: schemas, codecs: make(map[int]*goavro.Codec)}
+}
+
+// Decode переводит avro binary в JSON по схеме из заголовка сообщения
+func (a *Avro) Decode(value []byte) ([]byte, error) {
+	if len(value) < 5 || value[0] != magicByte {
+		return nil, errors.New("avro: missing schema id header")
+	}
+	id := int(binary.BigEndian.Uint32(value[1:5]))
+
+	codec, err := a.codec(id)
+	if err != nil {
+		return nil, fmt.Errorf("avro: %w", err)
+	}
+	native, rest, err := codec.NativeFromBinary(value[5:])
+	if err != nil {
+		return nil, fmt.Errorf("avro: schema %d: %w", id, err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("avro: schema %d: %d trailing bytes", id, len(rest))
+	}
+	out, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, fmt.Errorf("avro: schema %d: %w", id, err)
+	}
+	return out, nil
+}
+
+func (a *Avro) codec(id int) (*goavro.Codec, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.codecs[id]; ok {
+		return c, nil
+	}
+	schema, err := a.schemas.Schema(id)
+	if err != nil {
+		return nil, err
+	}
+	c, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	a.codecs[id] = c
+	return c, nil
+}
@@ -0,0 +1,101 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Типы содержимого, которые понимает сервис
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/avro"
+)
+
+// HeaderContentType заголовок Kafka-сообщения с типом содержимого
+const HeaderContentType = "content-type"
+
+// ErrUnsupportedContentType для типа содержимого нет декодера
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Decoder переводит значение сообщения в JSON, который дальше
+// разбирается как обычно (конверт, models.Order, валидация)
+type Decoder interface {
+	Decode(value []byte) ([]byte, error)
+}
+
+// DecoderFunc адаптер для обычной функции
+type DecoderFunc func(value []byte) ([]byte, error)
+
+func (f DecoderFunc) Decode(value []byte) ([]byte, error) { return f(value) }
+
+// JSON декодер по умолчанию: значение уже JSON
+var JSON Decoder = DecoderFunc(func(value []byte) ([]byte, error) { return value, nil })
+
+// Registry декодеры по типу содержимого
+type Registry struct {
+	decoders map[string]Decoder
+}
+
+// NewRegistry создаёт реестр с JSON-декодером
+func NewRegistry() *Registry {
+	r := &Registry{decoders: make(map[string]Decoder)}
+	r.Register(JSON, ContentTypeJSON, "text/json")
+	return r
+}
+
+// Default реестр со всеми поддерживаемыми форматами: JSON, Protobuf и Avro
+func Default(schemas SchemaRegistry) *Registry {
+	r := NewRegistry()
+	r.Register(Protobuf, ContentTypeProtobuf, "application/protobuf")
+	r.Register(NewAvro(schemas), ContentTypeAvro, "avro/binary")
+	return r
+}
+
+// Register привязывает декодер к одному или нескольким типам содержимого
+func (r *Registry) Register(d Decoder, contentTypes ...string) {
+	for _, ct := range contentTypes {
+		r.decoders[normalize(ct)] = d
+	}
+}
+
+// Lookup ищет декодер по значению заголовка content-type.
+// Пустой заголовок — JSON, как было до появления других форматов.
+func (r *Registry) Lookup(contentType string) (Decoder, error) {
+	ct := normalize(contentType)
+	if ct == "" {
+		ct = ContentTypeJSON
+	}
+	d, ok := r.decoders[ct]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+	return d, nil
+}
+
+// ContentType значение заголовка content-type без учёта регистра ключа
+func ContentType(headers map[string]string) string {
+	if v, ok := headers[HeaderContentType]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, HeaderContentType) {
+			return v
+		}
+	}
+	return ""
+}
+
+// normalize отбрасывает параметры (charset и т.п.) и регистр
+func normalize(ct string) string {
+	ct = strings.TrimSpace(ct)
+	if ct == "" {
+		return ""
+	}
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return strings.ToLower(ct)
+}
@@ -0,0 +1,415 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"yourmodule/internal/models"
+
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809",
+			City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot",
+			Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDT: 1637907727, Bank: "alpha",
+			DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453,
+			RID: "ab4219087a764ae0btest", Name: "Mascaras", Sale: 30, Size: "0",
+			TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OofShard:        "1",
+	}
+}
+
+// --- protobuf: кодируем по номерам полей из order.proto ---
+
+func appendStr(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendMsg(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func marshalOrder(o models.Order) []byte {
+	d := o.Delivery
+	var del []byte
+	del = appendStr(del, 1, d.Name)
+	del = appendStr(del, 2, d.Phone)
+	del = appendStr(del, 3, d.Zip)
+	del = appendStr(del, 4, d.City)
+	del = appendStr(del, 5, d.Address)
+	del = appendStr(del, 6, d.Region)
+	del = appendStr(del, 7, d.Email)
+
+	p := o.Payment
+	var pay []byte
+	pay = appendStr(pay, 1, p.Transaction)
+	pay = appendStr(pay, 2, p.RequestID)
+	pay = appendStr(pay, 3, p.Currency)
+	pay = appendStr(pay, 4, p.Provider)
+	pay = appendInt(pay, 5, int64(p.Amount))
+	pay = appendInt(pay, 6, p.PaymentDT)
+	pay = appendStr(pay, 7, p.Bank)
+	pay = appendInt(pay, 8, int64(p.DeliveryCost))
+	pay = appendInt(pay, 9, int64(p.GoodsTotal))
+	pay = appendInt(pay, 10, int64(p.CustomFee))
+
+	var b []byte
+	b = appendStr(b, 1, o.OrderUID)
+	b = appendStr(b, 2, o.TrackNumber)
+	b = appendStr(b, 3, o.Entry)
+	b = appendMsg(b, 4, del)
+	b = appendMsg(b, 5, pay)
+	for _, it := range o.Items {
+		var ib []byte
+		ib = appendInt(ib, 1, int64(it.ChrtID))
+		ib = appendStr(ib, 2, it.TrackNumber)
+		ib = appendInt(ib, 3, int64(it.Price))
+		ib = appendStr(ib, 4, it.RID)
+		ib = appendStr(ib, 5, it.Name)
+		ib = appendInt(ib, 6, int64(it.Sale))
+		ib = appendStr(ib, 7, it.Size)
+		ib = appendInt(ib, 8, int64(it.TotalPrice))
+		ib = appendInt(ib, 9, int64(it.NmID))
+		ib = appendStr(ib, 10, it.Brand)
+		ib = appendInt(ib, 11, int64(it.Status))
+		b = appendMsg(b, 6, ib)
+	}
+	b = appendStr(b, 7, o.Locale)
+	b = appendStr(b, 8, o.InternalSignature)
+	b = appendStr(b, 9, o.CustomerID)
+	b = appendStr(b, 10, o.DeliveryService)
+	b = appendStr(b, 11, o.ShardKey)
+	b = appendInt(b, 12, int64(o.SmID))
+	b = appendStr(b, 13, o.DateCreated)
+	b = appendStr(b, 14, o.OofShard)
+	return b
+}
+
+func decodeJSON(t *testing.T, data []byte) models.Order {
+	t.Helper()
+	var got models.Order
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decoder returned invalid JSON: %v\n%s", err, data)
+	}
+	return got
+}
+
+func TestProtobuf(t *testing.T) {
+	want := validOrder()
+	msg := marshalOrder(want)
+	// поле из будущей версии контракта должно пропускаться
+	msg = appendStr(msg, 99, "ignored")
+	msg = protowire.AppendTag(msg, 100, protowire.Fixed32Type)
+	msg = protowire.AppendFixed32(msg, 7)
+
+	out, err := Protobuf.Decode(msg)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := decodeJSON(t, out)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("decoded order must be valid: %v", err)
+	}
+}
+
+func TestProtobuf_Invalid(t *testing.T) {
+	// строковое поле с varint вместо bytes
+	bad := protowire.AppendTag(nil, 1, protowire.VarintType)
+	bad = protowire.AppendVarint(bad, 1)
+	if _, err := Protobuf.Decode(bad); err == nil {
+		t.Fatalf("expected wire type error")
+	}
+
+	// обрезанное сообщение
+	msg := marshalOrder(validOrder())
+	if _, err := Protobuf.Decode(msg[:len(msg)-2]); err == nil {
+		t.Fatalf("expected error for truncated message")
+	}
+}
+
+// --- protobuf: контракт из order.proto ---
+
+var (
+	protoMessage = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	protoField   = regexp.MustCompile(`(?m)^\s*(repeated )?(\w+) (\w+) = (\d+);`)
+)
+
+// orderProto дескриптор proto/order/v1/order.proto. protoc в сборке нет,
+// поэтому файл разбирается здесь; контракт простой: скаляры, вложенные и repeated сообщения.
+func orderProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	src, err := os.ReadFile("../../proto/order/v1/order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("order/v1/order.proto"),
+		Package: proto.String("order.v1"),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, m := range protoMessage.FindAllStringSubmatch(string(src), -1) {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(m[1])}
+		for _, f := range protoField.FindAllStringSubmatch(m[2], -1) {
+			num, _ := strconv.Atoi(f[4])
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(f[3]),
+				JsonName: proto.String(f[3]),
+				Number:   proto.Int32(int32(num)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if f[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			switch f[2] {
+			case "string":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()
+			case "int64":
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+			default:
+				if f[2] == "" || f[2][0] < 'A' || f[2][0] > 'Z' {
+					t.Fatalf("%s.%s: scalar type %s is not supported by the test", m[1], f[3], f[2])
+				}
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String(".order.v1." + f[2])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+		fd.MessageType = append(fd.MessageType, msg)
+	}
+	file, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		t.Fatalf("order.proto: %v", err)
+	}
+	return file
+}
+
+// fillProto заполняет все поля сообщения разными значениями и возвращает
+// ожидаемый JSON: имена полей контракта совпадают с JSON-тегами models.Order
+func fillProto(m protoreflect.Message, next *int64) map[string]any {
+	want := map[string]any{}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+		switch {
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			var items []any
+			list := m.Mutable(fd).List()
+			for j := 0; j < 2; j++ {
+				el := list.NewElement()
+				items = append(items, fillProto(el.Message(), next))
+				list.Append(el)
+			}
+			want[name] = items
+		case fd.Kind() == protoreflect.MessageKind:
+			want[name] = fillProto(m.Mutable(fd).Message(), next)
+		case fd.Kind() == protoreflect.StringKind:
+			*next++
+			v := name + "-" + strconv.FormatInt(*next, 10)
+			m.Set(fd, protoreflect.ValueOfString(v))
+			want[name] = v
+		case fd.Kind() == protoreflect.Int64Kind:
+			*next++
+			m.Set(fd, protoreflect.ValueOfInt64(*next))
+			want[name] = float64(*next)
+		}
+	}
+	return want
+}
+
+// TestProtobuf_MatchesProto кодирует заказ по дескриптору из order.proto и разбирает
+// его Protobuf: поле, добавленное в контракт или перенумерованное без правки
+// декодера (или модели), не придёт в JSON, и тест упадёт.
+func TestProtobuf_MatchesProto(t *testing.T) {
+	md := orderProto(t).Messages().ByName("Order")
+	if md == nil {
+		t.Fatal("order.proto has no Order message")
+	}
+	msg := dynamicpb.NewMessage(md)
+	var next int64
+	want := fillProto(msg, &next)
+
+	wire, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Protobuf.Decode(wire)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Fatalf("decoder out of sync with order.proto\n got: %s\nwant: %s", gotJSON, wantJSON)
+	}
+}
+
+// --- avro ---
+
+func avroMessage(t *testing.T, schema string, id uint32, text []byte) []byte {
+	t.Helper()
+	c, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatalf("codec: %v", err)
+	}
+	native, _, err := c.NativeFromTextual(text)
+	if err != nil {
+		t.Fatalf("native from textual: %v", err)
+	}
+	msg := []byte{magicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], id)
+	msg, err = c.BinaryFromNative(msg, native)
+	if err != nil {
+		t.Fatalf("binary from native: %v", err)
+	}
+	return msg
+}
+
+func TestAvro(t *testing.T) {
+	// схема, которая идёт в репозитории
+	dir := filepath.Join("..", "..", "schemas", "avro")
+	schema, err := os.ReadFile(filepath.Join(dir, "1.avsc"))
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+
+	want := validOrder()
+	text, _ := json.Marshal(want)
+	dec := NewAvro(NewFileRegistry(dir))
+	out, err := dec.Decode(avroMessage(t, string(schema), 1, text))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := decodeJSON(t, out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+
+	if _, err := dec.Decode([]byte(`{"order_uid":"1"}`)); err == nil {
+		t.Fatalf("expected error without wire format header")
+	}
+	if _, err := dec.Decode([]byte{magicByte, 0, 0, 0, 42, 1}); !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("expected ErrUnknownSchema, got %v", err)
+	}
+}
+
+type countingRegistry struct {
+	SchemaRegistry
+	calls int
+}
+
+func (r *countingRegistry) Schema(id int) (string, error) {
+	r.calls++
+	return r.SchemaRegistry.Schema(id)
+}
+
+func TestAvro_CachesSchemas(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{"type":"record","name":"Order","fields":[{"name":"order_uid","type":"string"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "7.avsc"), []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := &countingRegistry{SchemaRegistry: NewFileRegistry(dir)}
+	dec := NewAvro(reg)
+	msg := avroMessage(t, schema, 7, []byte(`{"order_uid":"42"}`))
+	for i := 0; i < 3; i++ {
+		out, err := dec.Decode(msg)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if string(out) != `{"order_uid":"42"}` {
+			t.Fatalf("unexpected JSON %s", out)
+		}
+	}
+	if reg.calls != 1 {
+		t.Fatalf("schema must be loaded once, loaded %d times", reg.calls)
+	}
+}
+
+// --- registry ---
+
+func TestRegistryLookup(t *testing.T) {
+	r := Default(NewFileRegistry(t.TempDir()))
+
+	cases := map[string]bool{
+		"":                                true,
+		"application/json":                true,
+		"Application/JSON; charset=utf-8": true,
+		"application/x-protobuf":          true,
+		"application/protobuf":            true,
+		"application/avro":                true,
+		"avro/binary":                     true,
+		"application/xml":                 false,
+	}
+	for ct, ok := range cases {
+		_, err := r.Lookup(ct)
+		if ok && err != nil {
+			t.Errorf("%q: unexpected error %v", ct, err)
+		}
+		if !ok && !errors.Is(err, ErrUnsupportedContentType) {
+			t.Errorf("%q: expected ErrUnsupportedContentType, got %v", ct, err)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	if got := ContentType(map[string]string{"Content-Type": "application/avro"}); got != "application/avro" {
+		t.Fatalf("header lookup must be case-insensitive, got %q", got)
+	}
+	if got := ContentType(nil); got != "" {
+		t.Fatalf("expected empty content type, got %q", got)
+	}
+}
@@ -0,0 +1,209 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"yourmodule/internal/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf декодирует order.v1.Order (proto/order/v1/order.proto).
+// Разбор идёт по номерам полей через protowire, без сгенерированного кода;
+// неизвестные поля пропускаются, как это делает protobuf.
+var Protobuf Decoder = DecoderFunc(decodeProtobuf)
+
+func decodeProtobuf(value []byte) ([]byte, error) {
+	var ord models.Order
+	if err := unmarshalOrder(value, &ord); err != nil {
+		return nil, fmt.Errorf("protobuf: %w", err)
+	}
+	return json.Marshal(ord)
+}
+
+var errWireType = errors.New("unexpected wire type")
+
+// field одно поле сообщения: для varint заполнен v, для bytes — raw
+type field struct {
+	num protowire.Number
+	typ protowire.Type
+	v   uint64
+	raw []byte
+}
+
+func (f field) str() (string, error) {
+	if f.typ != protowire.BytesType {
+		return "", fmt.Errorf("field %d: %w", f.num, errWireType)
+	}
+	return string(f.raw), nil
+}
+
+func (f field) int() (int64, error) {
+	if f.typ != protowire.VarintType {
+		return 0, fmt.Errorf("field %d: %w", f.num, errWireType)
+	}
+	return int64(f.v), nil
+}
+
+func (f field) msg() ([]byte, error) {
+	if f.typ != protowire.BytesType {
+		return nil, fmt.Errorf("field %d: %w", f.num, errWireType)
+	}
+	return f.raw, nil
+}
+
+// walk вызывает fn для каждого поля сообщения
+func walk(b []byte, fn func(field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.raw, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setters хелперы, чтобы таблицы полей читались как схема
+func setStr(dst *string) func(field) error {
+	return func(f field) (err error) { *dst, err = f.str(); return }
+}
+
+func setInt(dst *int) func(field) error {
+	return func(f field) error {
+		v, err := f.int()
+		*dst = int(v)
+		return err
+	}
+}
+
+func setInt64(dst *int64) func(field) error {
+	return func(f field) (err error) { *dst, err = f.int(); return }
+}
+
+// decodeFields разбирает сообщение по таблице номер поля -> setter
+func decodeFields(b []byte, fields map[protowire.Number]func(field) error) error {
+	return walk(b, func(f field) error {
+		set, ok := fields[f.num]
+		if !ok {
+			return nil
+		}
+		return set(f)
+	})
+}
+
+func unmarshalOrder(b []byte, o *models.Order) error {
+	return decodeFields(b, map[protowire.Number]func(field) error{
+		1: setStr(&o.OrderUID),
+		2: setStr(&o.TrackNumber),
+		3: setStr(&o.Entry),
+		4: func(f field) error {
+			raw, err := f.msg()
+			if err != nil {
+				return err
+			}
+			return unmarshalDelivery(raw, &o.Delivery)
+		},
+		5: func(f field) error {
+			raw, err := f.msg()
+			if err != nil {
+				return err
+			}
+			return unmarshalPayment(raw, &o.Payment)
+		},
+		6: func(f field) error {
+			raw, err := f.msg()
+			if err != nil {
+				return err
+			}
+			var it models.Item
+			if err := unmarshalItem(raw, &it); err != nil {
+				return fmt.Errorf("items[%d]: %w", len(o.Items), err)
+			}
+			o.Items = append(o.Items, it)
+			return nil
+		},
+		7:  setStr(&o.Locale),
+		8:  setStr(&o.InternalSignature),
+		9:  setStr(&o.CustomerID),
+		10: setStr(&o.DeliveryService),
+		11: setStr(&o.ShardKey),
+		12: setInt(&o.SmID),
+		13: setStr(&o.DateCreated),
+		14: setStr(&o.OofShard),
+	})
+}
+
+func unmarshalDelivery(b []byte, d *models.Delivery) error {
+	err := decodeFields(b, map[protowire.Number]func(field) error{
+		1: setStr(&d.Name),
+		2: setStr(&d.Phone),
+		3: setStr(&d.Zip),
+		4: setStr(&d.City),
+		5: setStr(&d.Address),
+		6: setStr(&d.Region),
+		7: setStr(&d.Email),
+	})
+	if err != nil {
+		return fmt.Errorf("delivery: %w", err)
+	}
+	return nil
+}
+
+func unmarshalPayment(b []byte, p *models.Payment) error {
+	err := decodeFields(b, map[protowire.Number]func(field) error{
+		1:  setStr(&p.Transaction),
+		2:  setStr(&p.RequestID),
+		3:  setStr(&p.Currency),
+		4:  setStr(&p.Provider),
+		5:  setInt(&p.Amount),
+		6:  setInt64(&p.PaymentDT),
+		7:  setStr(&p.Bank),
+		8:  setInt(&p.DeliveryCost),
+		9:  setInt(&p.GoodsTotal),
+		10: setInt(&p.CustomFee),
+	})
+	if err != nil {
+		return fmt.Errorf("payment: %w", err)
+	}
+	return nil
+}
+
+func unmarshalItem(b []byte, it *models.Item) error {
+	return decodeFields(b, map[protowire.Number]func(field) error{
+		1:  setInt(&it.ChrtID),
+		2:  setStr(&it.TrackNumber),
+		3:  setInt(&it.Price),
+		4:  setStr(&it.RID),
+		5:  setStr(&it.Name),
+		6:  setInt(&it.Sale),
+		7:  setStr(&it.Size),
+		8:  setInt(&it.TotalPrice),
+		9:  setInt(&it.NmID),
+		10: setStr(&it.Brand),
+		11: setInt(&it.Status),
+	})
+}
@@ -0,0 +1,700 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"yourmodule/internal/cache"
+	"yourmodule/internal/ratelimit"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config конфигурация сервиса.
+// Приоритет источников: значения по умолчанию < файл < env < флаги.
+type Config struct {
+	Role      Role            `yaml:"role" toml:"role"`
+	LogLevel  string          `yaml:"log_level" toml:"log_level"`
+	HTTP      HTTPConfig      `yaml:"http" toml:"http"`
+	DB        DBConfig        `yaml:"db" toml:"db"`
+	Kafka     KafkaConfig     `yaml:"kafka" toml:"kafka"`
+	Cache     CacheConfig     `yaml:"cache" toml:"cache"`
+	Warmup    WarmupConfig    `yaml:"warmup" toml:"warmup"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Outbox    OutboxConfig    `yaml:"outbox" toml:"outbox"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks" toml:"webhooks"`
+	Stream    StreamConfig    `yaml:"stream" toml:"stream"`
+}
+
+type HTTPConfig struct {
+	Addr            string        `yaml:"addr" toml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// BatchLimit сколько заказов можно запросить одним POST /orders:batchGet
+	BatchLimit int `yaml:"batch_limit" toml:"batch_limit"`
+}
+
+type DBConfig struct {
+	// DSN если задан, то Host/Port/User/Password/Name игнорируются
+	DSN             string        `yaml:"dsn" toml:"dsn"`
+	Host            string        `yaml:"host" toml:"host"`
+	Port            int           `yaml:"port" toml:"port"`
+	User            string        `yaml:"user" toml:"user"`
+	Password        string        `yaml:"password" toml:"password"`
+	Name            string        `yaml:"name" toml:"name"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode"`
+	ConnectAttempts int           `yaml:"connect_attempts" toml:"connect_attempts"`
+	ConnectBackoff  time.Duration `yaml:"connect_backoff" toml:"connect_backoff"`
+}
+
+type KafkaConfig struct {
+	Brokers         []string      `yaml:"brokers" toml:"brokers"`
+	Topic           string        `yaml:"topic" toml:"topic"`
+	Group           string        `yaml:"group" toml:"group"`
+	RetryBackoffMin time.Duration `yaml:"retry_backoff_min" toml:"retry_backoff_min"`
+	RetryBackoffMax time.Duration `yaml:"retry_backoff_max" toml:"retry_backoff_max"`
+	// StrictFields отклонять сообщения с неизвестными модели полями
+	StrictFields bool `yaml:"strict_fields" toml:"strict_fields"`
+	// AvroSchemaDir каталог со схемами Avro (<id>.avsc) вместо schema registry
+	AvroSchemaDir string `yaml:"avro_schema_dir" toml:"avro_schema_dir"`
+}
+
+// OutboxConfig публикация событий заказов из order_events в Kafka
+type OutboxConfig struct {
+	Topic     string        `yaml:"topic" toml:"topic"`
+	BatchSize int           `yaml:"batch_size" toml:"batch_size"`
+	Interval  time.Duration `yaml:"interval" toml:"interval"`
+	// Retention сколько хранить отправленные события; 0 — не удалять
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+}
+
+// WebhooksConfig доставка событий заказов на webhook'и партнёров
+type WebhooksConfig struct {
+	Workers     int           `yaml:"workers" toml:"workers"`
+	MaxAttempts int           `yaml:"max_attempts" toml:"max_attempts"`
+	BackoffMin  time.Duration `yaml:"backoff_min" toml:"backoff_min"`
+	BackoffMax  time.Duration `yaml:"backoff_max" toml:"backoff_max"`
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`
+	// Refresh как часто перечитывать подписки из БД
+	Refresh time.Duration `yaml:"refresh" toml:"refresh"`
+	// Poll как часто искать в журнале доставки, которым пора уйти
+	Poll time.Duration `yaml:"poll" toml:"poll"`
+}
+
+// StreamConfig поток новых заказов GET /orders/stream
+type StreamConfig struct {
+	// MaxClients предел одновременных подписчиков, 0 — без предела
+	MaxClients int `yaml:"max_clients" toml:"max_clients"`
+	// Buffer сколько событий копится на подписчика, прежде чем его отключат
+	Buffer int `yaml:"buffer" toml:"buffer"`
+}
+
+type CacheConfig struct {
+	// Backend memory — кэш процесса, redis — общий Redis, tiered — кэш процесса перед Redis
+	Backend         string        `yaml:"backend" toml:"backend"`
+	TTL             time.Duration `yaml:"ttl" toml:"ttl"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval"`
+	// Shards на сколько частей со своими блокировками делить кэш
+	Shards int `yaml:"shards" toml:"shards"`
+	// SnapshotFile куда сохранять кэш при остановке и откуда поднимать при старте; пусто — не сохранять
+	SnapshotFile string `yaml:"snapshot_file" toml:"snapshot_file"`
+	// Invalidation сбрасывать заказ в кэше процесса, когда его сохранила любая реплика
+	// (LISTEN/NOTIFY Postgres)
+	Invalidation bool `yaml:"invalidation" toml:"invalidation"`
+	// LocalTTL срок записей кэша процесса в режиме tiered
+	LocalTTL time.Duration `yaml:"local_ttl" toml:"local_ttl"`
+	Redis    RedisConfig   `yaml:"redis" toml:"redis"`
+}
+
+// RedisConfig подключение к Redis для cache.backend redis и tiered
+type RedisConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
+	// KeyPrefix добавляется к order_uid, чтобы делить Redis с другими сервисами
+	KeyPrefix string `yaml:"key_prefix" toml:"key_prefix"`
+	// Timeout предел одной операции; при превышении заказ читается из БД
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// WarmupConfig прогрев кэша при старте
+type WarmupConfig struct {
+	// Strategy recent, popular, window или export
+	Strategy string        `yaml:"strategy" toml:"strategy"`
+	Limit    int           `yaml:"limit" toml:"limit"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout"`
+	// Window глубина по date_created для стратегии window
+	Window time.Duration `yaml:"window" toml:"window"`
+	// AccessFile счётчики обращений к кэшу: пишутся при остановке, читаются стратегией popular
+	AccessFile string `yaml:"access_file" toml:"access_file"`
+	// ExportFile выгрузка NDJSON, чьи заказы загружает стратегия export
+	ExportFile string `yaml:"export_file" toml:"export_file"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	Insecure bool   `yaml:"insecure" toml:"insecure"`
+}
+
+// AuthConfig аутентификация HTTP API. Если ничего не задано, API открыт.
+type AuthConfig struct {
+	// APIKeys в формате subject:role:key
+	APIKeys          []string `yaml:"api_keys" toml:"api_keys"`
+	JWTSecret        string   `yaml:"jwt_secret" toml:"jwt_secret"`
+	JWTPublicKeyFile string   `yaml:"jwt_public_key_file" toml:"jwt_public_key_file"`
+	JWTIssuer        string   `yaml:"jwt_issuer" toml:"jwt_issuer"`
+	JWTAudience      string   `yaml:"jwt_audience" toml:"jwt_audience"`
+}
+
+// Enabled сообщает, настроен ли хотя бы один способ аутентификации
+func (c AuthConfig) Enabled() bool {
+	return len(c.APIKeys) > 0 || c.JWTSecret != "" || c.JWTPublicKeyFile != ""
+}
+
+// RateLimitConfig ограничение частоты запросов к HTTP API на клиента
+type RateLimitConfig struct {
+	// Default правило rps:burst для маршрутов без своего правила; 0:0 или пусто — выключено
+	Default string `yaml:"default" toml:"default"`
+	// Routes правила маршрутов в формате /order/{order_uid}=rps:burst
+	Routes []string `yaml:"routes" toml:"routes"`
+	// TrustProxy брать IP клиента из X-Forwarded-For
+	TrustProxy bool `yaml:"trust_proxy" toml:"trust_proxy"`
+}
+
+// Rules разбирает правила по умолчанию и для маршрутов
+func (c RateLimitConfig) Rules() (ratelimit.Rule, map[string]ratelimit.Rule, error) {
+	var def ratelimit.Rule
+	if c.Default != "" {
+		r, err := ratelimit.ParseRule(c.Default)
+		if err != nil {
+			return def, nil, err
+		}
+		def = r
+	}
+	routes := make(map[string]ratelimit.Rule, len(c.Routes))
+	for _, entry := range c.Routes {
+		route, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return def, nil, fmt.Errorf("rate_limit.routes entry %q must look like route=rps:burst", entry)
+		}
+		r, err := ratelimit.ParseRule(rule)
+		if err != nil {
+			return def, nil, err
+		}
+		routes[strings.TrimSpace(route)] = r
+	}
+	return def, routes, nil
+}
+
+// Enabled включено ли ограничение хотя бы для одного маршрута
+func (c RateLimitConfig) Enabled() bool {
+	return c.Default != "" || len(c.Routes) > 0
+}
+
+// Role режим запуска бинарника
+type Role string
+
+const (
+	// RoleAll HTTP API, Kafka consumer и фоновые задачи в одном процессе
+	RoleAll Role = "all"
+	// RoleAPI только HTTP API с кэшем и его прогревом
+	RoleAPI Role = "api"
+	// RoleConsumer только приём заказов из Kafka
+	RoleConsumer Role = "consumer"
+	// RoleWorker Kafka consumer и фоновые задачи без HTTP
+	RoleWorker Role = "worker"
+)
+
+// Roles все допустимые роли
+var Roles = []Role{RoleAll, RoleAPI, RoleConsumer, RoleWorker}
+
+// Valid сообщает, известна ли роль
+func (r Role) Valid() bool {
+	for _, v := range Roles {
+		if r == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ServesHTTP нужен ли HTTP сервер и прогрев кэша
+func (r Role) ServesHTTP() bool { return r == RoleAll || r == RoleAPI }
+
+// ConsumesKafka нужен ли Kafka consumer
+func (r Role) ConsumesKafka() bool { return r != RoleAPI }
+
+// RunsBackground нужны ли фоновые задачи
+func (r Role) RunsBackground() bool { return r == RoleAll || r == RoleWorker }
+
+// Default возвращает конфигурацию по умолчанию
+func Default() Config {
+	return Config{
+		Role:     RoleAll,
+		LogLevel: "info",
+		HTTP: HTTPConfig{
+			Addr:            ":8082",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			BatchLimit:      500,
+		},
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			ConnectAttempts: 20,
+			ConnectBackoff:  2 * time.Second,
+		},
+		Kafka: KafkaConfig{
+			Brokers:         []string{"kafka:9092"},
+			Topic:           "orders",
+			Group:           "order-service-group",
+			RetryBackoffMin: 500 * time.Millisecond,
+			RetryBackoffMax: 10 * time.Second,
+			AvroSchemaDir:   "./schemas/avro",
+		},
+		Cache: CacheConfig{
+			Backend:         "memory",
+			TTL:             5 * time.Minute,
+			CleanupInterval: time.Minute,
+			Shards:          cache.DefaultShards,
+			Invalidation:    true,
+			LocalTTL:        30 * time.Second,
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "order:",
+				Timeout:   100 * time.Millisecond,
+			},
+		},
+		Warmup: WarmupConfig{
+			Strategy: "recent",
+			Limit:    1000,
+			Timeout:  30 * time.Second,
+			Window:   72 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+			Insecure: true,
+		},
+		RateLimit: RateLimitConfig{
+			Default: "10:20",
+		},
+		Outbox: OutboxConfig{
+			Topic:     "order-events",
+			BatchSize: 100,
+			Interval:  time.Second,
+			Retention: 7 * 24 * time.Hour,
+		},
+		Webhooks: WebhooksConfig{
+			Workers:     4,
+			MaxAttempts: 6,
+			BackoffMin:  time.Second,
+			BackoffMax:  time.Minute,
+			Timeout:     10 * time.Second,
+			Refresh:     30 * time.Second,
+			Poll:        time.Second,
+		},
+		Stream: StreamConfig{
+			MaxClients: 100,
+			Buffer:     64,
+		},
+	}
+}
+
+// binding связывает поле конфига с переменной окружения и флагом
+type binding struct {
+	flag  string
+	env   string
+	usage string
+	ptr   any
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"role", "SERVICE_ROLE", "service role: all, api, consumer, worker", (*string)(&c.Role)},
+		{"log-level", "LOG_LEVEL", "log level: debug, info, warn, error", &c.LogLevel},
+
+		{"http.addr", "HTTP_PORT", "HTTP listen address", &c.HTTP.Addr},
+		{"http.read-timeout", "HTTP_READ_TIMEOUT", "HTTP read timeout", &c.HTTP.ReadTimeout},
+		{"http.write-timeout", "HTTP_WRITE_TIMEOUT", "HTTP write timeout", &c.HTTP.WriteTimeout},
+		{"http.idle-timeout", "HTTP_IDLE_TIMEOUT", "HTTP idle timeout", &c.HTTP.IdleTimeout},
+		{"http.shutdown-timeout", "HTTP_SHUTDOWN_TIMEOUT", "graceful shutdown timeout", &c.HTTP.ShutdownTimeout},
+		{"http.batch-limit", "HTTP_BATCH_LIMIT", "max order_uids per POST /orders:batchGet", &c.HTTP.BatchLimit},
+
+		{"db.dsn", "PG_DSN", "Postgres DSN (overrides db.host and friends)", &c.DB.DSN},
+		{"db.host", "DB_HOST", "Postgres host", &c.DB.Host},
+		{"db.port", "DB_PORT", "Postgres port", &c.DB.Port},
+		{"db.user", "DB_USER", "Postgres user", &c.DB.User},
+		{"db.password", "DB_PASSWORD", "Postgres password", &c.DB.Password},
+		{"db.name", "DB_NAME", "Postgres database", &c.DB.Name},
+		{"db.sslmode", "DB_SSLMODE", "Postgres sslmode", &c.DB.SSLMode},
+		{"db.connect-attempts", "DB_CONNECT_ATTEMPTS", "DB connect attempts on startup", &c.DB.ConnectAttempts},
+		{"db.connect-backoff", "DB_CONNECT_BACKOFF", "pause between DB connect attempts", &c.DB.ConnectBackoff},
+
+		{"kafka.brokers", "KAFKA_BROKERS", "comma-separated Kafka brokers", &c.Kafka.Brokers},
+		{"kafka.topic", "KAFKA_TOPIC", "Kafka topic with orders", &c.Kafka.Topic},
+		{"kafka.group", "KAFKA_GROUP", "Kafka consumer group", &c.Kafka.Group},
+		{"kafka.retry-backoff-min", "KAFKA_RETRY_BACKOFF_MIN", "initial consumer restart backoff", &c.Kafka.RetryBackoffMin},
+		{"kafka.retry-backoff-max", "KAFKA_RETRY_BACKOFF_MAX", "max consumer restart backoff", &c.Kafka.RetryBackoffMax},
+		{"kafka.strict-fields", "KAFKA_STRICT_FIELDS", "reject messages with unknown fields", &c.Kafka.StrictFields},
+		{"kafka.avro-schema-dir", "KAFKA_AVRO_SCHEMA_DIR", "directory with Avro schemas named <id>.avsc", &c.Kafka.AvroSchemaDir},
+
+		{"cache.backend", "CACHE_BACKEND", "order cache: memory, redis, tiered", &c.Cache.Backend},
+		{"cache.ttl", "CACHE_TTL", "cache entry TTL", &c.Cache.TTL},
+		{"cache.cleanup-interval", "CACHE_CLEANUP_INTERVAL", "cache GC interval", &c.Cache.CleanupInterval},
+		{"cache.shards", "CACHE_SHARDS", "cache shards, each with its own lock", &c.Cache.Shards},
+		{"cache.invalidation", "CACHE_INVALIDATION", "evict orders saved by other replicas via Postgres LISTEN/NOTIFY", &c.Cache.Invalidation},
+		{"cache.local-ttl", "CACHE_LOCAL_TTL", "in-process entry TTL in front of Redis (tiered)", &c.Cache.LocalTTL},
+		{"cache.redis.addr", "REDIS_ADDR", "Redis host:port", &c.Cache.Redis.Addr},
+		{"cache.redis.password", "REDIS_PASSWORD", "Redis password", &c.Cache.Redis.Password},
+		{"cache.redis.db", "REDIS_DB", "Redis database number", &c.Cache.Redis.DB},
+		{"cache.redis.key-prefix", "REDIS_KEY_PREFIX", "prefix for order keys in Redis", &c.Cache.Redis.KeyPrefix},
+		{"cache.redis.timeout", "REDIS_TIMEOUT", "Redis operation timeout", &c.Cache.Redis.Timeout},
+		{"cache.snapshot-file", "CACHE_SNAPSHOT_FILE", "cache snapshot saved on shutdown and restored on startup", &c.Cache.SnapshotFile},
+
+		{"warmup.limit", "WARMUP_LIMIT", "orders loaded into cache on startup", &c.Warmup.Limit},
+		{"warmup.timeout", "WARMUP_TIMEOUT", "cache warmup timeout", &c.Warmup.Timeout},
+		{"warmup.strategy", "WARMUP_STRATEGY", "cache warmup strategy: recent, popular, window, export", &c.Warmup.Strategy},
+		{"warmup.window", "WARMUP_WINDOW", "how far back the window strategy loads orders", &c.Warmup.Window},
+		{"warmup.access-file", "WARMUP_ACCESS_FILE", "cache access counters saved on shutdown for the popular strategy", &c.Warmup.AccessFile},
+		{"warmup.export-file", "WARMUP_EXPORT_FILE", "NDJSON export listing the orders warmed by the export strategy", &c.Warmup.ExportFile},
+
+		{"tracing.exporter", "TRACING_EXPORTER", "trace exporter: none, stdout, otlp", &c.Tracing.Exporter},
+		{"tracing.endpoint", "TRACING_ENDPOINT", "OTLP/HTTP collector host:port", &c.Tracing.Endpoint},
+		{"tracing.insecure", "TRACING_INSECURE", "disable TLS for OTLP exporter", &c.Tracing.Insecure},
+
+		{"auth.api-keys", "AUTH_API_KEYS", "comma-separated API keys as subject:role:key", &c.Auth.APIKeys},
+		{"auth.jwt-secret", "AUTH_JWT_SECRET", "HS256 JWT secret", &c.Auth.JWTSecret},
+		{"auth.jwt-public-key-file", "AUTH_JWT_PUBLIC_KEY_FILE", "PEM file with RS256 JWT public key", &c.Auth.JWTPublicKeyFile},
+		{"auth.jwt-issuer", "AUTH_JWT_ISSUER", "expected JWT iss claim", &c.Auth.JWTIssuer},
+		{"auth.jwt-audience", "AUTH_JWT_AUDIENCE", "expected JWT aud claim", &c.Auth.JWTAudience},
+
+		{"rate-limit.default", "RATE_LIMIT_DEFAULT", "per-client rate limit as rps:burst, 0:0 disables", &c.RateLimit.Default},
+		{"rate-limit.routes", "RATE_LIMIT_ROUTES", "comma-separated per-route limits as route=rps:burst", &c.RateLimit.Routes},
+		{"rate-limit.trust-proxy", "RATE_LIMIT_TRUST_PROXY", "use X-Forwarded-For as client IP", &c.RateLimit.TrustProxy},
+
+		{"outbox.topic", "OUTBOX_TOPIC", "Kafka topic for order events", &c.Outbox.Topic},
+		{"outbox.batch-size", "OUTBOX_BATCH_SIZE", "order events published per batch", &c.Outbox.BatchSize},
+		{"outbox.interval", "OUTBOX_INTERVAL", "outbox polling interval", &c.Outbox.Interval},
+		{"outbox.retention", "OUTBOX_RETENTION", "how long to keep sent events, 0 keeps forever", &c.Outbox.Retention},
+
+		{"webhooks.workers", "WEBHOOKS_WORKERS", "parallel webhook deliveries", &c.Webhooks.Workers},
+		{"webhooks.max-attempts", "WEBHOOKS_MAX_ATTEMPTS", "webhook delivery attempts before giving up", &c.Webhooks.MaxAttempts},
+		{"webhooks.backoff-min", "WEBHOOKS_BACKOFF_MIN", "pause before the first webhook retry", &c.Webhooks.BackoffMin},
+		{"webhooks.backoff-max", "WEBHOOKS_BACKOFF_MAX", "max pause between webhook retries", &c.Webhooks.BackoffMax},
+		{"webhooks.timeout", "WEBHOOKS_TIMEOUT", "webhook request timeout", &c.Webhooks.Timeout},
+		{"webhooks.refresh", "WEBHOOKS_REFRESH", "how often to reload webhook subscriptions", &c.Webhooks.Refresh},
+		{"webhooks.poll", "WEBHOOKS_POLL", "how often to look for due webhook deliveries", &c.Webhooks.Poll},
+
+		{"stream.max-clients", "STREAM_MAX_CLIENTS", "max concurrent /orders/stream clients, 0 is unlimited", &c.Stream.MaxClients},
+		{"stream.buffer", "STREAM_BUFFER", "events buffered per stream client before it is dropped", &c.Stream.Buffer},
+	}
+}
+
+// Load собирает конфиг из файла (-config или CONFIG_FILE), окружения
+// и флагов командной строки, после чего валидирует результат
+func Load(args []string) (Config, error) {
+	return LoadFlags(flag.NewFlagSet("order-service", flag.ContinueOnError), args)
+}
+
+// LoadFlags как Load, но разбирает args набором fs: подкоманды заводят
+// в нём свои флаги до вызова и читают их после
+func LoadFlags(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+	binds := cfg.bindings()
+
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or TOML config file")
+
+	// значения флагов применяются последними, поэтому сначала только запоминаем их
+	flagValues := make(map[string]string)
+	for _, b := range binds {
+		fs.Func(b.flag, b.usage+" (env "+b.env+")", func(v string) error {
+			flagValues[b.flag] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(*configPath, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, b := range binds {
+		if v, ok := os.LookupEnv(b.env); ok && v != "" {
+			if err := set(b.ptr, v); err != nil {
+				return cfg, fmt.Errorf("env %s: %w", b.env, err)
+			}
+		}
+	}
+	for _, b := range binds {
+		if v, ok := flagValues[b.flag]; ok {
+			if err := set(b.ptr, v); err != nil {
+				return cfg, fmt.Errorf("flag -%s: %w", b.flag, err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// loadFile читает YAML или TOML в зависимости от расширения
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// set разбирает строковое значение в поле по указателю
+func set(ptr any, v string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		var out []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+		*p = out
+	default:
+		return fmt.Errorf("unsupported field type %T", ptr)
+	}
+	return nil
+}
+
+// Validate проверяет согласованность конфига
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Role.Valid(), "unknown role %q", c.Role)
+	check(c.HTTP.Addr != "", "http.addr is required")
+	check(c.HTTP.ReadTimeout > 0, "http.read_timeout must be positive")
+	check(c.HTTP.WriteTimeout > 0, "http.write_timeout must be positive")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+	check(c.HTTP.BatchLimit > 0, "http.batch_limit must be positive")
+
+	if c.DB.DSN == "" {
+		check(c.DB.Host != "", "db.host is required when db.dsn is empty")
+		check(c.DB.Name != "", "db.name is required when db.dsn is empty")
+		check(c.DB.Port > 0 && c.DB.Port < 65536, "db.port out of range: %d", c.DB.Port)
+	}
+	check(c.DB.ConnectAttempts > 0, "db.connect_attempts must be positive")
+
+	check(len(c.Kafka.Brokers) > 0, "kafka.brokers is required")
+	check(c.Kafka.Topic != "", "kafka.topic is required")
+	check(c.Kafka.Group != "", "kafka.group is required")
+	check(c.Kafka.RetryBackoffMin > 0 && c.Kafka.RetryBackoffMin <= c.Kafka.RetryBackoffMax,
+		"kafka retry backoff must satisfy 0 < min <= max")
+
+	if c.Role.RunsBackground() {
+		check(c.Outbox.Topic != "", "outbox.topic is required")
+		check(c.Outbox.Topic != c.Kafka.Topic, "outbox.topic must differ from kafka.topic")
+		check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+		check(c.Outbox.Interval > 0, "outbox.interval must be positive")
+		check(c.Outbox.Retention >= 0, "outbox.retention must not be negative")
+	}
+
+	if c.Role.ConsumesKafka() {
+		check(c.Webhooks.Workers > 0, "webhooks.workers must be positive")
+		check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts must be positive")
+		check(c.Webhooks.BackoffMin > 0 && c.Webhooks.BackoffMin <= c.Webhooks.BackoffMax,
+			"webhooks backoff must satisfy 0 < min <= max")
+		check(c.Webhooks.Timeout > 0, "webhooks.timeout must be positive")
+		check(c.Webhooks.Refresh > 0, "webhooks.refresh must be positive")
+		check(c.Webhooks.Poll > 0, "webhooks.poll must be positive")
+	}
+
+	check(c.Stream.MaxClients >= 0, "stream.max_clients must not be negative")
+	check(c.Stream.Buffer > 0, "stream.buffer must be positive")
+
+	check(c.Cache.TTL >= 0, "cache.ttl must not be negative")
+	check(c.Cache.CleanupInterval >= 0, "cache.cleanup_interval must not be negative")
+	check(c.Cache.Shards > 0, "cache.shards must be positive")
+	switch c.Cache.Backend {
+	case "memory":
+	case "redis", "tiered":
+		check(c.Cache.Redis.Addr != "", "cache.redis.addr is required for the %s cache backend", c.Cache.Backend)
+		check(c.Cache.Redis.Timeout > 0, "cache.redis.timeout must be positive")
+		if c.Cache.Backend == "tiered" {
+			check(c.Cache.LocalTTL > 0, "cache.local_ttl must be positive")
+		} else {
+			// снапшот и счётчики обращений берутся из кэша процесса, а его нет
+			check(c.Cache.SnapshotFile == "", "cache.snapshot_file needs the memory or tiered cache backend")
+			check(c.Warmup.AccessFile == "", "warmup.access_file needs the memory or tiered cache backend")
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown cache.backend %q", c.Cache.Backend))
+	}
+	check(c.Warmup.Limit >= 0, "warmup.limit must not be negative")
+	check(c.Warmup.Timeout > 0, "warmup.timeout must be positive")
+	switch c.Warmup.Strategy {
+	case "recent":
+	case "window":
+		check(c.Warmup.Window > 0, "warmup.window must be positive")
+	case "popular":
+		check(c.Warmup.AccessFile != "", "warmup.access_file is required for the popular strategy")
+	case "export":
+		check(c.Warmup.ExportFile != "", "warmup.export_file is required for the export strategy")
+	default:
+		errs = append(errs, fmt.Errorf("unknown warmup.strategy %q", c.Warmup.Strategy))
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error", "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		errs = append(errs, fmt.Errorf("unknown log_level %q", c.LogLevel))
+	}
+	for _, k := range c.Auth.APIKeys {
+		if parts := strings.SplitN(k, ":", 3); len(parts) != 3 || parts[2] == "" {
+			errs = append(errs, errors.New("auth.api_keys entries must look like subject:role:key"))
+			break
+		}
+	}
+
+	if _, _, err := c.RateLimit.Rules(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("unknown tracing.exporter %q", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+// PostgresDSN возвращает DSN из db.dsn или собирает его из отдельных полей
+func (c DBConfig) PostgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.Host + ":" + strconv.Itoa(c.Port),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {c.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+const redacted = "xxxxx"
+
+var kvPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted возвращает копию конфига со скрытыми секретами
+func (c Config) Redacted() Config {
+	out := c
+	out.Kafka.Brokers = append([]string(nil), c.Kafka.Brokers...)
+	if out.DB.Password != "" {
+		out.DB.Password = redacted
+	}
+	out.DB.DSN = redactDSN(c.DB.DSN)
+	out.Auth.APIKeys = nil
+	for _, k := range c.Auth.APIKeys {
+		// subject и роль оставляем, прячем только сам ключ
+		if parts := strings.SplitN(k, ":", 3); len(parts) == 3 {
+			out.Auth.APIKeys = append(out.Auth.APIKeys, parts[0]+":"+parts[1]+":"+redacted)
+		} else {
+			out.Auth.APIKeys = append(out.Auth.APIKeys, redacted)
+		}
+	}
+	if out.Auth.JWTSecret != "" {
+		out.Auth.JWTSecret = redacted
+	}
+	if out.Cache.Redis.Password != "" {
+		out.Cache.Redis.Password = redacted
+	}
+	return out
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	// key=value формат
+	return kvPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Dump сериализует конфиг без секретов в YAML
+func (c Config) Dump() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config dump failed: %v", err)
+	}
+	return string(data)
+}
+
+// LogValue позволяет логировать конфиг целиком, не раскрывая секреты
+func (c Config) LogValue() slog.Value {
+	var m map[string]any
+	if err := yaml.Unmarshal([]byte(c.Dump()), &m); err != nil {
+		return slog.StringValue(c.Dump())
+	}
+	return slog.AnyValue(m)
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("DB_NAME", "orders")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Cache.TTL != 5*time.Minute || cfg.Warmup.Limit != 1000 {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "cfg.yaml", `
+http:
+  addr: ":9000"
+kafka:
+  topic: from-file
+  brokers: [a:9092, b:9092]
+cache:
+  ttl: 2m
+db:
+  dsn: postgres://u:p@h:5432/d
+`)
+	t.Setenv("KAFKA_TOPIC", "from-env")
+
+	cfg, err := Load([]string{"-config", path, "-http.addr", ":9100"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.HTTP.Addr != ":9100" {
+		t.Fatalf("flag must override file, got %s", cfg.HTTP.Addr)
+	}
+	if cfg.Kafka.Topic != "from-env" {
+		t.Fatalf("env must override file, got %s", cfg.Kafka.Topic)
+	}
+	if len(cfg.Kafka.Brokers) != 2 || cfg.Cache.TTL != 2*time.Minute {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeFile(t, "cfg.toml", `
+log_level = "debug"
+
+[db]
+dsn = "postgres://u:p@h:5432/d"
+
+[warmup]
+limit = 50
+timeout = "5s"
+`)
+
+	cfg, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.Warmup.Limit != 50 || cfg.Warmup.Timeout != 5*time.Second {
+		t.Fatalf("toml values not applied: %+v", cfg)
+	}
+}
+
+func TestLoad_ValidationError(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+	t.Setenv("WARMUP_LIMIT", "-1")
+
+	_, err := Load([]string{"-tracing.exporter", "zipkin"})
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if !strings.Contains(err.Error(), "warmup.limit") || !strings.Contains(err.Error(), "tracing.exporter") {
+		t.Fatalf("expected all problems reported, got: %v", err)
+	}
+}
+
+func TestPostgresDSN_Escaping(t *testing.T) {
+	c := DBConfig{Host: "db", Port: 5432, User: "u", Password: "p@ss/word", Name: "orders", SSLMode: "disable"}
+
+	got := c.PostgresDSN()
+	want := "postgres://u:p%40ss%2Fword@db:5432/orders?sslmode=disable"
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.DB.Password = "hunter2"
+	cfg.DB.DSN = "postgres://user:hunter2@db:5432/orders"
+	cfg.Auth.APIKeys = []string{"ops:admin:hunter2"}
+	cfg.Auth.JWTSecret = "hunter2"
+	cfg.Cache.Redis.Password = "hunter2"
+
+	dump := cfg.Dump()
+	if strings.Contains(dump, "hunter2") {
+		t.Fatalf("dump leaks secrets:\n%s", dump)
+	}
+	if !strings.Contains(dump, "ops:admin:") {
+		t.Fatalf("dump must keep API key subject and role:\n%s", dump)
+	}
+	if cfg.DB.Password != "hunter2" || cfg.Auth.APIKeys[0] != "ops:admin:hunter2" {
+		t.Fatalf("Redacted must not modify the original")
+	}
+
+	if got := redactDSN("host=db user=u password=hunter2 dbname=x"); strings.Contains(got, "hunter2") {
+		t.Fatalf("key=value DSN leaks password: %s", got)
+	}
+}
+
+func TestLoad_Role(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+
+	cfg, err := Load([]string{"-role", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Role.ServesHTTP() || cfg.Role.ConsumesKafka() {
+		t.Fatalf("api role must serve HTTP only, got %+v", cfg.Role)
+	}
+
+	if _, err := Load([]string{"-role", "scheduler"}); err == nil {
+		t.Fatalf("expected error for unknown role")
+	}
+}
+
+func TestRole_Components(t *testing.T) {
+	tests := []struct {
+		role                    Role
+		http, kafka, background bool
+	}{
+		{RoleAll, true, true, true},
+		{RoleAPI, true, false, false},
+		{RoleConsumer, false, true, false},
+		{RoleWorker, false, true, true},
+	}
+	for _, tt := range tests {
+		if tt.role.ServesHTTP() != tt.http || tt.role.ConsumesKafka() != tt.kafka || tt.role.RunsBackground() != tt.background {
+			t.Fatalf("unexpected components for role %s", tt.role)
+		}
+	}
+}
+
+func TestLoad_OutboxOnlyForBackgroundRoles(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+	t.Setenv("OUTBOX_TOPIC", "orders")
+
+	// outbox не должен писать в топик, который сам же читает consumer
+	if _, err := Load([]string{"-role", "worker"}); err == nil || !strings.Contains(err.Error(), "outbox.topic") {
+		t.Fatalf("expected outbox.topic error, got %v", err)
+	}
+	if _, err := Load([]string{"-role", "api"}); err != nil {
+		t.Fatalf("api role has no outbox relay, unexpected error: %v", err)
+	}
+}
+
+func TestLoadFlags_SubcommandFlags(t *testing.T) {
+	t.Setenv("DB_NAME", "orders")
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "ndjson", "")
+	cfg, err := LoadFlags(fs, []string{"-format", "csv", "-db.name", "archive"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *format != "csv" || cfg.DB.Name != "archive" {
+		t.Fatalf("expected both subcommand and config flags, got %q %q", *format, cfg.DB.Name)
+	}
+}
+
+func TestValidate_WarmupStrategy(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+
+	if _, err := Load([]string{"-warmup.strategy", "popular"}); err == nil || !strings.Contains(err.Error(), "warmup.access_file") {
+		t.Fatalf("popular without access file must fail, got %v", err)
+	}
+	if _, err := Load([]string{"-warmup.strategy", "lru"}); err == nil || !strings.Contains(err.Error(), "warmup.strategy") {
+		t.Fatalf("unknown strategy must fail, got %v", err)
+	}
+	cfg, err := Load([]string{"-warmup.strategy", "window", "-warmup.window", "24h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Warmup.Window != 24*time.Hour {
+		t.Fatalf("window not applied: %+v", cfg.Warmup)
+	}
+}
+
+func TestValidate_CacheBackend(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+
+	if _, err := Load([]string{"-cache.backend", "memcached"}); err == nil || !strings.Contains(err.Error(), "cache.backend") {
+		t.Fatalf("unknown backend must fail, got %v", err)
+	}
+	_, err := Load([]string{"-cache.backend", "redis", "-cache.redis.addr", "", "-cache.snapshot-file", "cache.snap"})
+	if err == nil || !strings.Contains(err.Error(), "cache.redis.addr") || !strings.Contains(err.Error(), "cache.snapshot_file") {
+		t.Fatalf("expected redis addr and snapshot problems, got %v", err)
+	}
+
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	cfg, err := Load([]string{"-cache.backend", "tiered", "-cache.local-ttl", "10s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Cache.Redis.Addr != "redis:6379" || cfg.Cache.LocalTTL != 10*time.Second {
+		t.Fatalf("redis settings not applied: %+v", cfg.Cache)
+	}
+}
+
+func TestLoad_CacheInvalidation(t *testing.T) {
+	t.Setenv("PG_DSN", "postgres://u:p@h/d")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Cache.Invalidation {
+		t.Fatalf("invalidation must be on by default")
+	}
+	cfg, err = Load([]string{"-cache.invalidation=false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Cache.Invalidation {
+		t.Fatalf("flag must turn invalidation off")
+	}
+}
@@ -1,34 +1,54 @@
 package consumer
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"time"
+	"unicode/utf8"
 
+	"yourmodule/internal/codec"
+	"yourmodule/internal/envelope"
+	"yourmodule/internal/logging"
 	"yourmodule/internal/models"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("yourmodule/internal/consumer")
+
 // Message обёртка Kafka-сообщения
 type Message struct {
 	Value     []byte
+	Headers   map[string]string
 	Partition int
 	Offset    int64
 }
 
 // OrderStore интерфейс для работы с БД
 type OrderStore interface {
-	SaveOrder(ctx context.Context, ord models.Order, raw []byte) error
+	// SaveOrder возвращает true, если заказ новый
+	SaveOrder(ctx context.Context, ord models.Order, raw []byte) (created bool, savedAt time.Time, err error)
 	SaveBadMessage(ctx context.Context, raw []byte, errText string) error
 }
 
 // Cache интерфейс для кэша
 type Cache interface {
-	Set(key string, value any, ttl time.Duration)
-	Get(key string) (any, bool)
+	Set(key string, value models.StoredOrder, ttl time.Duration)
+	Get(key string) (models.StoredOrder, bool)
+}
+
+// Notifier получает событие после того, как заказ сохранён в БД и кэш
+type Notifier interface {
+	Notify(ctx context.Context, eventType string, so models.StoredOrder)
 }
 
 // Reader интерфейс для kafka.Reader
@@ -40,14 +60,51 @@ type Reader interface {
 
 // Consumer основной потребитель сообщений
 type Consumer struct {
-	reader Reader
-	store  OrderStore
-	cache  Cache
+	reader    Reader
+	store     OrderStore
+	cache     Cache
+	strict    bool
+	schemas   *envelope.Registry
+	decoders  *codec.Registry
+	notifiers []Notifier
+}
+
+// Option настраивает Consumer
+type Option func(*Consumer)
+
+// WithStrictFields отклоняет заказы с неизвестными модели полями
+func WithStrictFields(strict bool) Option {
+	return func(c *Consumer) { c.strict = strict }
+}
+
+// WithSchemaRegistry задаёт реестр версий схемы и upcaster'ов
+func WithSchemaRegistry(r *envelope.Registry) Option {
+	return func(c *Consumer) { c.schemas = r }
+}
+
+// WithDecoders задаёт декодеры по content-type; по умолчанию только JSON
+func WithDecoders(r *codec.Registry) Option {
+	return func(c *Consumer) { c.decoders = r }
+}
+
+// WithNotifier подписывает получателя на события сохранённых заказов
+func WithNotifier(n Notifier) Option {
+	return func(c *Consumer) { c.notifiers = append(c.notifiers, n) }
 }
 
 // New создаёт нового Consumer
-func New(reader Reader, store OrderStore, cache Cache) *Consumer {
-	return &Consumer{reader: reader, store: store, cache: cache}
+func New(reader Reader, store OrderStore, cache Cache, opts ...Option) *Consumer {
+	c := &Consumer{
+		reader:   reader,
+		store:    store,
+		cache:    cache,
+		schemas:  envelope.NewOrderRegistry(),
+		decoders: codec.NewRegistry(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Run запускает цикл обработки сообщений
@@ -56,43 +113,126 @@ func (c *Consumer) Run(ctx context.Context) {
 		m, err := c.reader.FetchMessage(ctx)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
-				log.Println("consumer context canceled")
+				slog.InfoContext(ctx, "consumer context canceled")
 				return
 			}
-			log.Printf("fetch message error: %v", err)
+			slog.ErrorContext(ctx, "fetch message failed", "err", err)
 			time.Sleep(time.Second)
 			continue
 		}
 
-		var ord models.Order
-		if err := json.Unmarshal(m.Value, &ord); err != nil {
-			log.Printf("invalid JSON: %v", err)
-			_ = c.store.SaveBadMessage(ctx, m.Value, "json_unmarshal: "+err.Error())
-			_ = c.reader.CommitMessages(ctx, m)
-			continue
-		}
+		c.handle(ctx, m)
+	}
+}
 
-		if err := ord.Validate(); err != nil {
+// handle обрабатывает одно сообщение в рамках span'а,
+// продолжающего трейс из заголовков Kafka
+func (c *Consumer) handle(ctx context.Context, m Message) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m.Headers))
+	ctx = logging.With(ctx, "partition", m.Partition, "offset", m.Offset)
+	ctx, span := tracer.Start(ctx, "consumer.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.Int("messaging.kafka.partition", m.Partition),
+			attribute.Int64("messaging.kafka.offset", m.Offset),
+		),
+	)
+	defer span.End()
 
-			log.Printf(
-				"invalid order uid=%s err=%v",
-				ord.OrderUID,
-				err,
-			)
-			_ = c.store.SaveBadMessage(ctx, m.Value, "validation: "+err.Error())
-			_ = c.reader.CommitMessages(ctx, m)
-			continue
-		}
+	// бинарные форматы переводим в JSON, дальше путь общий
+	contentType := codec.ContentType(m.Headers)
+	data, err := c.decode(contentType, m.Value)
+	if err != nil {
+		slog.WarnContext(ctx, "decode failed", "err", err, "content_type", contentType)
+		span.SetStatus(codes.Error, "decode failed")
+		_ = c.store.SaveBadMessage(ctx, badRaw(m.Value), "decode: "+err.Error())
+		_ = c.reader.CommitMessages(ctx, m)
+		return
+	}
 
-		if err := c.store.SaveOrder(ctx, ord, m.Value); err != nil {
-			log.Printf("DB save error: %v", err)
-			time.Sleep(time.Second)
-			continue
-		}
+	// конверт снимается, payload приводится к текущей версии схемы
+	payload, version, err := c.schemas.Decode(data)
+	if err != nil {
+		slog.WarnContext(ctx, "invalid envelope", "err", err, "schema_version", version)
+		span.SetStatus(codes.Error, "invalid envelope")
+		_ = c.store.SaveBadMessage(ctx, badRaw(m.Value), "envelope: "+err.Error())
+		_ = c.reader.CommitMessages(ctx, m)
+		return
+	}
+	span.SetAttributes(attribute.Int("messaging.schema_version", version))
 
-		c.cache.Set(ord.OrderUID, ord, time.Minute)
+	var ord models.Order
+	if err := json.Unmarshal(payload, &ord); err != nil {
+		slog.WarnContext(ctx, "invalid JSON", "err", err)
+		span.SetStatus(codes.Error, "invalid json")
+		_ = c.store.SaveBadMessage(ctx, badRaw(m.Value), "json_unmarshal: "+err.Error())
 		_ = c.reader.CommitMessages(ctx, m)
+		return
+	}
+	span.SetAttributes(attribute.String("order.uid", ord.OrderUID))
+	ctx = logging.With(ctx, "order_uid", ord.OrderUID)
+
+	validate := ord.Validate
+	if c.strict {
+		validate = ord.ValidateStrict
+	}
+	if err := validate(); err != nil {
+		slog.WarnContext(ctx, "invalid order", "err", err)
+		span.SetStatus(codes.Error, "validation failed")
+		_ = c.store.SaveBadMessage(ctx, badRaw(m.Value), "validation: "+err.Error())
+		_ = c.reader.CommitMessages(ctx, m)
+		return
+	}
+
+	created, savedAt, err := c.store.SaveOrder(ctx, ord, payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "DB save failed", "err", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "db save failed")
+		time.Sleep(time.Second)
+		return
+	}
+
+	_, cacheSpan := tracer.Start(ctx, "cache.set",
+		trace.WithAttributes(attribute.String("order.uid", ord.OrderUID)))
+	// created_at из БД, а не время процесса: по нему считаются ETag/Last-Modified,
+	// и он же совпадает с тем, что отдаст чтение из БД
+	stored := models.StoredOrder{
+		Order:     ord,
+		Payload:   payload,
+		CreatedAt: savedAt,
 	}
+	c.cache.Set(ord.OrderUID, stored, 0)
+	cacheSpan.End()
+
+	eventType := models.EventOrderUpdated
+	if created {
+		eventType = models.EventOrderCreated
+	}
+	for _, n := range c.notifiers {
+		n.Notify(ctx, eventType, stored)
+	}
+
+	_ = c.reader.CommitMessages(ctx, m)
+	slog.DebugContext(ctx, "order saved")
+}
+
+func (c *Consumer) decode(contentType string, value []byte) ([]byte, error) {
+	d, err := c.decoders.Lookup(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return d.Decode(value)
+}
+
+// badRaw исходное сообщение для bad_messages. Колонка текстовая,
+// поэтому бинарные сообщения сохраняются в base64.
+func badRaw(value []byte) []byte {
+	if utf8.Valid(value) && !bytes.ContainsRune(value, 0) {
+		return value
+	}
+	return []byte("base64:" + base64.StdEncoding.EncodeToString(value))
 }
 
 // Close закрывает reader
@@ -111,8 +251,13 @@ func (k *KafkaReaderWrapper) FetchMessage(ctx context.Context) (Message, error)
 	if err != nil {
 		return Message{}, err
 	}
+	headers := make(map[string]string, len(m.Headers))
+	for _, h := range m.Headers {
+		headers[h.Key] = string(h.Value)
+	}
 	return Message{
 		Value:     m.Value,
+		Headers:   headers,
 		Partition: m.Partition,
 		Offset:    m.Offset,
 	}, nil
@@ -3,37 +3,58 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
+	"yourmodule/internal/codec"
 	"yourmodule/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // --------- FAKE CACHE ---------
 type fakeCache struct {
-	data map[string]interface{}
+	data map[string]models.StoredOrder
 }
 
 func newFakeCache() *fakeCache {
-	return &fakeCache{data: map[string]interface{}{}}
+	return &fakeCache{data: map[string]models.StoredOrder{}}
 }
 
-func (f *fakeCache) Set(key string, val any, _ time.Duration) {
+func (f *fakeCache) Set(key string, val models.StoredOrder, _ time.Duration) {
 	f.data[key] = val
 }
 
-func (f *fakeCache) Get(key string) (any, bool) {
+func (f *fakeCache) Get(key string) (models.StoredOrder, bool) {
 	v, ok := f.data[key]
 	return v, ok
 }
 
 // --------- FAKE STORE ---------
-type fakeStore struct{}
+type fakeStore struct {
+	saved  []string
+	badErr []string
+}
 
-func (f *fakeStore) SaveOrder(ctx context.Context, ord models.Order, raw []byte) error {
-	return nil
+// savedAt created_at, который «возвращает БД»
+var savedAt = time.Date(2026, 1, 16, 15, 58, 0, 0, time.UTC)
+
+func (f *fakeStore) SaveOrder(ctx context.Context, ord models.Order, raw []byte) (bool, time.Time, error) {
+	for _, uid := range f.saved {
+		if uid == ord.OrderUID {
+			f.saved = append(f.saved, ord.OrderUID)
+			return false, savedAt, nil
+		}
+	}
+	f.saved = append(f.saved, ord.OrderUID)
+	return true, savedAt, nil
 }
 
 func (f *fakeStore) SaveBadMessage(ctx context.Context, raw []byte, errText string) error {
+	f.badErr = append(f.badErr, errText)
 	return nil
 }
 
@@ -59,10 +80,8 @@ func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...Message) error
 func (f *fakeReader) Close() error { return nil }
 
 // --------- TESTS ---------
-func TestConsumerRun(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	validOrder := models.Order{
+func newValidOrder() models.Order {
+	return models.Order{
 		OrderUID:    "123",
 		TrackNumber: "TN12345",
 		Entry:       "entry",
@@ -105,6 +124,12 @@ func TestConsumerRun(t *testing.T) {
 		DateCreated:     "2026-01-16T15:58:00Z",
 		OofShard:        "oof",
 	}
+}
+
+func TestConsumerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	validOrder := newValidOrder()
 
 	validJSON, err := json.Marshal(validOrder)
 	if err != nil {
@@ -127,15 +152,180 @@ func TestConsumerRun(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 	cancel()
 
-	v, ok := cache.Get("123")
+	orderCached, ok := cache.Get("123")
 	if !ok {
 		t.Fatalf("order not cached")
 	}
-	orderCached, ok := v.(models.Order)
-	if !ok {
-		t.Fatalf("cached value has wrong type")
+	if orderCached.Order.OrderUID != "123" {
+		t.Fatalf("cached order UID mismatch, got %s", orderCached.Order.OrderUID)
+	}
+	if string(orderCached.Payload) != string(validJSON) {
+		t.Fatalf("cached payload mismatch")
+	}
+	if !orderCached.CreatedAt.Equal(savedAt) {
+		t.Fatalf("cached created_at must come from the DB, got %v", orderCached.CreatedAt)
+	}
+}
+
+func TestConsumerRun_PropagatesTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	// span "продюсера", контекст которого уходит в заголовки
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "producer.send")
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(parentCtx, headers)
+	parent.End()
+
+	validJSON, err := json.Marshal(newValidOrder())
+	if err != nil {
+		t.Fatalf("failed to marshal order: %v", err)
+	}
+
+	reader := &fakeReader{
+		messages: []Message{
+			{Value: validJSON, Headers: headers},
+		},
+	}
+
+	New(reader, &fakeStore{}, newFakeCache()).Run(context.Background())
+
+	var found bool
+	for _, s := range rec.Ended() {
+		if s.Name() != "consumer.process" {
+			continue
+		}
+		found = true
+		if s.Parent().TraceID() != parent.SpanContext().TraceID() {
+			t.Fatalf("trace id not propagated: got %s", s.Parent().TraceID())
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("parent span mismatch: got %s", s.Parent().SpanID())
+		}
+	}
+	if !found {
+		t.Fatalf("consumer.process span not recorded")
+	}
+}
+
+func TestConsumerRun_StrictFields(t *testing.T) {
+	validJSON, err := json.Marshal(newValidOrder())
+	if err != nil {
+		t.Fatalf("failed to marshal order: %v", err)
+	}
+	withExtra := append(validJSON[:len(validJSON)-1:len(validJSON)-1], []byte(`,"loyalty_tier":"gold"}`)...)
+
+	// без strict неизвестные поля сохраняются вместе с заказом
+	store := &fakeStore{}
+	New(&fakeReader{messages: []Message{{Value: withExtra}}}, store, newFakeCache()).Run(context.Background())
+	if len(store.saved) != 1 || len(store.badErr) != 0 {
+		t.Fatalf("expected order to be saved, got saved=%v bad=%v", store.saved, store.badErr)
+	}
+
+	store = &fakeStore{}
+	New(&fakeReader{messages: []Message{{Value: withExtra}}}, store, newFakeCache(), WithStrictFields(true)).
+		Run(context.Background())
+	if len(store.saved) != 0 || len(store.badErr) != 1 {
+		t.Fatalf("expected bad message, got saved=%v bad=%v", store.saved, store.badErr)
+	}
+	if !strings.Contains(store.badErr[0], "loyalty_tier") {
+		t.Fatalf("bad message must name the unknown field: %s", store.badErr[0])
 	}
-	if orderCached.OrderUID != "123" {
-		t.Fatalf("cached order UID mismatch, got %s", orderCached.OrderUID)
+}
+
+func TestConsumerRun_Envelope(t *testing.T) {
+	validJSON, err := json.Marshal(newValidOrder())
+	if err != nil {
+		t.Fatalf("failed to marshal order: %v", err)
+	}
+	wrapped := []byte(`{"schema_version":1,"type":"order","payload":` + string(validJSON) + `}`)
+	future := []byte(`{"schema_version":2,"type":"order","payload":` + string(validJSON) + `}`)
+
+	store := &fakeStore{}
+	cache := newFakeCache()
+	reader := &fakeReader{messages: []Message{{Value: wrapped}, {Value: future}}}
+	New(reader, store, cache).Run(context.Background())
+
+	if len(store.saved) != 1 || len(store.badErr) != 1 {
+		t.Fatalf("expected one saved and one bad message, got saved=%v bad=%v", store.saved, store.badErr)
+	}
+	if !strings.HasPrefix(store.badErr[0], "envelope:") {
+		t.Fatalf("unexpected bad message reason: %s", store.badErr[0])
+	}
+
+	// в кэш и БД уходит payload без конверта
+	v, _ := cache.Get("123")
+	if string(v.Payload) != string(validJSON) {
+		t.Fatalf("cached payload must be unwrapped")
+	}
+}
+
+func TestConsumerRun_ContentType(t *testing.T) {
+	validJSON, err := json.Marshal(newValidOrder())
+	if err != nil {
+		t.Fatalf("failed to marshal order: %v", err)
+	}
+
+	// бинарный формат подменяем декодером, который возвращает готовый JSON
+	decoders := codec.NewRegistry()
+	decoders.Register(codec.DecoderFunc(func(v []byte) ([]byte, error) {
+		if string(v) != "\x00binary" {
+			t.Errorf("decoder got unexpected value %q", v)
+		}
+		return validJSON, nil
+	}), codec.ContentTypeProtobuf)
+
+	store := &fakeStore{}
+	reader := &fakeReader{messages: []Message{
+		{Value: []byte("\x00binary"), Headers: map[string]string{"Content-Type": "application/x-protobuf"}},
+		{Value: []byte("\x00binary"), Headers: map[string]string{"content-type": "application/xml"}},
+	}}
+	New(reader, store, newFakeCache(), WithDecoders(decoders)).Run(context.Background())
+
+	if len(store.saved) != 1 || len(store.badErr) != 1 {
+		t.Fatalf("expected one saved and one bad message, got saved=%v bad=%v", store.saved, store.badErr)
+	}
+	if !strings.HasPrefix(store.badErr[0], "decode:") {
+		t.Fatalf("unexpected bad message reason: %s", store.badErr[0])
+	}
+}
+
+func TestBadRaw(t *testing.T) {
+	if got := string(badRaw([]byte(`{"a":1}`))); got != `{"a":1}` {
+		t.Fatalf("text message must be kept as is, got %s", got)
+	}
+	if got := string(badRaw([]byte{0, 1, 2})); got != "base64:AAEC" {
+		t.Fatalf("binary message must be base64 encoded, got %s", got)
+	}
+}
+
+type recordingNotifier struct {
+	events []string
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, eventType string, so models.StoredOrder) {
+	n.events = append(n.events, eventType+":"+so.Order.OrderUID)
+}
+
+func TestConsumerRun_Notifier(t *testing.T) {
+	validJSON, err := json.Marshal(newValidOrder())
+	if err != nil {
+		t.Fatalf("failed to marshal order: %v", err)
+	}
+
+	n := &recordingNotifier{}
+	reader := &fakeReader{messages: []Message{
+		{Value: validJSON},
+		{Value: []byte(`{bad json}`)},
+		{Value: validJSON},
+	}}
+	New(reader, &fakeStore{}, newFakeCache(), WithNotifier(n)).Run(context.Background())
+
+	want := []string{models.EventOrderCreated + ":123", models.EventOrderUpdated + ":123"}
+	if strings.Join(n.events, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected events %v, got %v", want, n.events)
 	}
 }
@@ -4,17 +4,64 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
+	"yourmodule/internal/invalidation"
 	"yourmodule/internal/models"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("yourmodule/internal/db")
+
+// startSpan открывает client-span запроса к Postgres; orderUID пустой
+// у запросов не к одному заказу
+func startSpan(ctx context.Context, op, orderUID string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", op),
+	}
+	if orderUID != "" {
+		attrs = append(attrs, attribute.String("order.uid", orderUID))
+	}
+	return tracer.Start(ctx, "db."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan фиксирует ошибку в span'е и закрывает его
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 type Store struct {
 	pool *pgxpool.Pool
+	// notify рассылать уведомления о сохранённых заказах, replica — от чьего имени
+	notify  bool
+	replica string
 }
 
-func NewStore(ctx context.Context, dsn string) (*Store, error) {
+// Option настраивает Store
+type Option func(*Store)
+
+// WithInvalidation SaveOrder уведомляет через InvalidationChannel о сохранённых
+// заказах; replica — id процесса, по нему он узнаёт и пропускает свои уведомления.
+// Без этой опции уведомлений нет.
+func WithInvalidation(replica string) Option {
+	return func(s *Store) { s.notify, s.replica = true, replica }
+}
+
+func NewStore(ctx context.Context, dsn string, opts ...Option) (*Store, error) {
 	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
@@ -23,20 +70,30 @@ func NewStore(ctx context.Context, dsn string) (*Store, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Store{pool: pool}, nil
+	s := &Store{pool: pool}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
 func (s *Store) Close() { s.pool.Close() }
 
-func (s *Store) SaveOrder(ctx context.Context, ord models.Order, rawJSON []byte) error {
+// SaveOrder сохраняет заказ и событие outbox в одной транзакции.
+// created — заказ новый, а не повторно пришедший; savedAt — его created_at в БД.
+func (s *Store) SaveOrder(ctx context.Context, ord models.Order, rawJSON []byte) (created bool, savedAt time.Time, err error) {
+	ctx, span := startSpan(ctx, "SaveOrder", ord.OrderUID)
+	defer func() { endSpan(span, err) }()
+
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return false, time.Time{}, err
 	}
 	defer tx.Rollback(ctx)
 
-	// вставка с upsert по PK order_uid
-	_, err = tx.Exec(ctx, `
+	// вставка с upsert по PK order_uid; xmax = 0 только у только что вставленной строки
+	var inserted bool
+	err = tx.QueryRow(ctx, `
         INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id,
                             delivery_service, shardkey, sm_id, date_created, oof_shard, payload)
         VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
@@ -52,62 +109,268 @@ func (s *Store) SaveOrder(ctx context.Context, ord models.Order, rawJSON []byte)
             sm_id = EXCLUDED.sm_id,
             date_created = EXCLUDED.date_created,
             oof_shard = EXCLUDED.oof_shard,
-            created_at = now()
+            -- created_at служит версией заказа в кэше. SET выполняется уже под блокировкой
+            -- строки, так что clock_timestamp() позже, чем у предыдущей записи, а now()
+            -- (начало транзакции) мог бы оказаться раньше
+            created_at = clock_timestamp()
+        RETURNING (xmax = 0), created_at
     `, ord.OrderUID, ord.TrackNumber, ord.Entry, ord.Locale, ord.InternalSignature, ord.CustomerID,
-		ord.DeliveryService, ord.ShardKey, ord.SmID, ord.DateCreated, ord.OofShard, rawJSON)
+		ord.DeliveryService, ord.ShardKey, ord.SmID, ord.DateCreated, ord.OofShard, rawJSON).Scan(&inserted, &savedAt)
 	if err != nil {
-		return err
+		return false, time.Time{}, err
 	}
 
-	return tx.Commit(ctx)
+	// событие для outbox в той же транзакции: либо есть и заказ, и событие, либо ничего
+	eventType := models.EventOrderUpdated
+	if inserted {
+		eventType = models.EventOrderCreated
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_events (order_uid, event_type, payload)
+		VALUES ($1, $2, $3)
+	`, ord.OrderUID, eventType, rawJSON)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	// уведомление уходит подписчикам только при коммите, так что реплики
+	// сбрасывают кэш, когда новая версия уже видна в БД
+	if s.notify {
+		ev, _ := json.Marshal(invalidation.Event{
+			OrderUID: ord.OrderUID,
+			Version:  savedAt.UnixNano(),
+			Replica:  s.replica,
+		})
+		if _, err = tx.Exec(ctx, `SELECT pg_notify($1, $2)`, InvalidationChannel, string(ev)); err != nil {
+			return false, time.Time{}, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, time.Time{}, err
+	}
+	return inserted, savedAt.UTC(), nil
 }
 
-func (s *Store) GetOrder(ctx context.Context, orderUID string) (models.Order, []byte, error) {
-	var raw json.RawMessage
-	var o models.Order
-	row := s.pool.QueryRow(ctx, `SELECT payload FROM orders WHERE order_uid = $1`, orderUID)
-	err := row.Scan(&raw)
+// InvalidationChannel канал LISTEN/NOTIFY с invalidation.Event сохранённых заказов (JSON)
+const InvalidationChannel = "order_cache_invalidate"
+
+// ListenInvalidations подписывается на InvalidationChannel на отдельном соединении
+// и вызывает fn на каждый сохранённый заказ. onListen вызывается, когда
+// подписка установлена. Возвращает ошибку при потере соединения, nil — при отмене ctx.
+func (s *Store) ListenInvalidations(ctx context.Context, onListen func(), fn func(invalidation.Event)) error {
+	pc, err := s.pool.Acquire(ctx)
 	if err != nil {
-		return o, nil, err
+		return err
+	}
+	// соединение с LISTEN нельзя возвращать в пул, поэтому забираем его насовсем
+	conn := pc.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{InvalidationChannel}.Sanitize()); err != nil {
+		return err
 	}
-	if err := json.Unmarshal(raw, &o); err != nil {
-		return o, raw, err
+	onListen()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var ev invalidation.Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil || ev.OrderUID == "" {
+			// чужой формат: считаем payload order_uid без версии, сброс безусловный
+			ev = invalidation.Event{OrderUID: n.Payload}
+		}
+		fn(ev)
 	}
-	return o, raw, nil
 }
 
-func (s *Store) LoadAllOrders(ctx context.Context, limit int) (map[string]models.Order, error) {
-	out := make(map[string]models.Order)
-	q := `SELECT payload FROM orders ORDER BY created_at DESC`
-	if limit > 0 {
-		q = q + fmt.Sprintf(" LIMIT %d", limit)
+func (s *Store) GetOrder(ctx context.Context, orderUID string) (_ models.StoredOrder, err error) {
+	ctx, span := startSpan(ctx, "GetOrder", orderUID)
+	defer func() { endSpan(span, err) }()
+
+	var so models.StoredOrder
+	row := s.pool.QueryRow(ctx, `SELECT payload, created_at FROM orders WHERE order_uid = $1`, orderUID)
+	err = row.Scan(&so.Payload, &so.CreatedAt)
+	if err != nil {
+		return so, err
+	}
+	if err := json.Unmarshal(so.Payload, &so.Order); err != nil {
+		return so, err
 	}
+	return so, nil
+}
+
+// GetOrders достаёт заказы одним запросом; отсутствующих uid нет в результате.
+// Заказы с битым payload не теряются среди отсутствующих: их uid в corrupt.
+func (s *Store) GetOrders(ctx context.Context, orderUIDs []string) (_ map[string]models.StoredOrder, corrupt []string, err error) {
+	ctx, span := startSpan(ctx, "GetOrders", "")
+	span.SetAttributes(attribute.Int("orders.requested", len(orderUIDs)))
+	defer func() { endSpan(span, err) }()
 
-	rows, err := s.pool.Query(ctx, q)
+	rows, err := s.pool.Query(ctx, `
+		SELECT order_uid, payload, created_at FROM orders WHERE order_uid = ANY($1)
+	`, orderUIDs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
+	out := make(map[string]models.StoredOrder, len(orderUIDs))
 	for rows.Next() {
-		var raw json.RawMessage
-		if err := rows.Scan(&raw); err != nil {
-			return nil, fmt.Errorf("LoadAllOrders scan: %w", err)
+		var uid string
+		var so models.StoredOrder
+		if err := rows.Scan(&uid, &so.Payload, &so.CreatedAt); err != nil {
+			return nil, nil, fmt.Errorf("GetOrders scan: %w", err)
 		}
-
-		var o models.Order
-		if err := json.Unmarshal(raw, &o); err != nil {
-			// можно логировать и continue
+		if err := json.Unmarshal(so.Payload, &so.Order); err != nil {
+			slog.WarnContext(ctx, "corrupt order payload", "order_uid", uid, "err", err)
+			corrupt = append(corrupt, uid)
 			continue
 		}
-		out[o.OrderUID] = o
+		out[uid] = so
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("GetOrders rows: %w", err)
+	}
+	span.SetAttributes(attribute.Int("orders.corrupt", len(corrupt)))
+	return out, corrupt, nil
+}
+
+// cursorFetchSize сколько строк StreamOrders забирает из курсора за раз
+const cursorFetchSize = 500
+
+// CorruptOrderError payload заказа в БД не разбирается в models.Order
+type CorruptOrderError struct {
+	OrderUID string
+	Err      error
+}
+
+func (e *CorruptOrderError) Error() string {
+	return fmt.Sprintf("corrupt payload of order %s: %v", e.OrderUID, e.Err)
+}
+
+func (e *CorruptOrderError) Unwrap() error { return e.Err }
+
+// StreamOrders отдаёт заказы под фильтр по одному через серверный курсор:
+// строки забираются пачками по cursorFetchSize, память не зависит от размера выборки.
+//
+// Для строки с битым payload fn получает заказ только с OrderUID, Payload и CreatedAt
+// и *CorruptOrderError: вернуть nil — пропустить строку, ошибку — прервать выборку.
+// Ошибка fn возвращается как есть.
+func (s *Store) StreamOrders(ctx context.Context, f models.OrderFilter, fn func(models.StoredOrder, error) error) (err error) {
+	ctx, span := tracer.Start(ctx, "db.StreamOrders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "StreamOrders"),
+			attribute.Int("db.limit", f.Limit),
+		),
+	)
+	var st streamStats
+	defer func() {
+		span.SetAttributes(attribute.Int("orders.streamed", st.rows), attribute.Int("orders.corrupt", st.corrupt))
+		endSpan(span, err)
+	}()
+
+	// курсор живёт только внутри транзакции; она только читает, поэтому в конце откатывается
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	q, args := orderFilterQuery(f)
+	if _, err = tx.Exec(ctx, `DECLARE orders_stream NO SCROLL CURSOR FOR `+q, args...); err != nil {
+		return fmt.Errorf("StreamOrders declare: %w", err)
+	}
+
+	for {
+		n, err := fetchOrders(ctx, tx, &st, fn)
+		if err != nil {
+			return err
+		}
+		if n < cursorFetchSize {
+			return nil
+		}
 	}
+}
 
+type streamStats struct {
+	rows, corrupt int
+}
+
+// fetchOrders забирает из курсора следующую пачку и возвращает число строк в ней
+func fetchOrders(ctx context.Context, tx pgx.Tx, st *streamStats, fn func(models.StoredOrder, error) error) (int, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM orders_stream`, cursorFetchSize))
+	if err != nil {
+		return 0, fmt.Errorf("StreamOrders fetch: %w", err)
+	}
+	defer rows.Close()
+
+	var n int
+	for rows.Next() {
+		n++
+		var so models.StoredOrder
+		if err := rows.Scan(&so.Order.OrderUID, &so.Payload, &so.CreatedAt); err != nil {
+			return n, fmt.Errorf("StreamOrders scan: %w", err)
+		}
+		var rowErr error
+		if err := json.Unmarshal(so.Payload, &so.Order); err != nil {
+			st.corrupt++
+			rowErr = &CorruptOrderError{OrderUID: so.Order.OrderUID, Err: err}
+			so.Order = models.Order{OrderUID: so.Order.OrderUID}
+		} else {
+			st.rows++
+		}
+		if err := fn(so, rowErr); err != nil {
+			return n, err
+		}
+	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("LoadAllOrders rows: %w", err)
+		return n, fmt.Errorf("StreamOrders rows: %w", err)
+	}
+	return n, nil
+}
+
+// orderFilterQuery собирает SELECT по фильтру; все значения, включая LIMIT, — параметры
+func orderFilterQuery(f models.OrderFilter) (string, []any) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "date_created >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "date_created < "+arg(f.To))
+	}
+	if f.DeliveryService != "" {
+		where = append(where, "delivery_service = "+arg(f.DeliveryService))
+	}
+	if f.CustomerID != "" {
+		where = append(where, "customer_id = "+arg(f.CustomerID))
 	}
 
-	return out, nil
+	q := `SELECT order_uid, payload, created_at FROM orders`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	switch f.Sort {
+	case models.SortRecentlySaved:
+		q += ` ORDER BY created_at DESC, order_uid`
+	default:
+		q += ` ORDER BY date_created, order_uid`
+	}
+	if f.Limit > 0 {
+		q += ` LIMIT ` + arg(f.Limit)
+	}
+	return q, args
 }
 
 func (s *Store) SaveBadMessage(ctx context.Context, raw []byte, errText string) error {
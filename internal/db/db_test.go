@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"yourmodule/internal/models"
 )
@@ -12,7 +14,7 @@ import (
 /************* FAKE STORE / MOCK *************/
 
 type fakeStore struct {
-	data            map[string]models.Order
+	data            map[string]models.StoredOrder
 	BadMessageRaw   []byte
 	BadMessageErr   string
 	BadMessageSaved bool
@@ -20,7 +22,7 @@ type fakeStore struct {
 
 func newFakeStoreWithSpy() *fakeStore {
 	return &fakeStore{
-		data: make(map[string]models.Order),
+		data: make(map[string]models.StoredOrder),
 	}
 }
 
@@ -31,33 +33,38 @@ func (f *fakeStore) SaveBadMessage(ctx context.Context, raw []byte, errText stri
 	return nil
 }
 
-func (f *fakeStore) GetOrder(ctx context.Context, orderUID string) (models.Order, []byte, error) {
-	o, ok := f.data[orderUID]
+func (f *fakeStore) GetOrder(ctx context.Context, orderUID string) (models.StoredOrder, error) {
+	so, ok := f.data[orderUID]
 	if !ok {
-		return models.Order{}, nil, errors.New("not found")
+		return models.StoredOrder{}, errors.New("not found")
 	}
-	raw, _ := json.Marshal(o)
-	return o, raw, nil
+	return so, nil
 }
 
 func newFakeStore() *fakeStore {
-	return &fakeStore{data: map[string]models.Order{}}
+	return &fakeStore{data: map[string]models.StoredOrder{}}
 }
 
-func (f *fakeStore) SaveOrder(ctx context.Context, ord models.Order, raw []byte) error {
+func (f *fakeStore) SaveOrder(ctx context.Context, ord models.Order, raw []byte) (bool, time.Time, error) {
 	if f.data == nil {
-		return errors.New("data not initialized")
+		return false, time.Time{}, errors.New("data not initialized")
 	}
-	f.data[ord.OrderUID] = ord
-	return nil
+	_, exists := f.data[ord.OrderUID]
+	so := models.StoredOrder{Order: ord, Payload: raw, CreatedAt: time.Now()}
+	f.data[ord.OrderUID] = so
+	return !exists, so.CreatedAt, nil
 }
 
-func (f *fakeStore) LoadAllOrders(ctx context.Context, limit int) (map[string]models.Order, error) {
-	out := make(map[string]models.Order)
-	for k, v := range f.data {
-		out[k] = v
+func (f *fakeStore) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(models.StoredOrder, error) error) error {
+	for _, v := range f.data {
+		if filter.CustomerID != "" && v.Order.CustomerID != filter.CustomerID {
+			continue
+		}
+		if err := fn(v, nil); err != nil {
+			return err
+		}
 	}
-	return out, nil
+	return nil
 }
 
 /************* TESTS *************/
@@ -68,20 +75,27 @@ func TestStore_SaveGetOrder(t *testing.T) {
 
 	order := models.Order{OrderUID: "123"}
 	raw, _ := json.Marshal(order)
-	if err := store.SaveOrder(ctx, order, raw); err != nil {
+	created, _, err := store.SaveOrder(ctx, order, raw)
+	if err != nil {
 		t.Fatalf("SaveOrder failed: %v", err)
 	}
+	if !created {
+		t.Fatalf("first save must report a new order")
+	}
+	if created, _, _ := store.SaveOrder(ctx, order, raw); created {
+		t.Fatalf("repeated save must report an update")
+	}
 
-	got, rawGot, err := store.GetOrder(ctx, "123")
+	got, err := store.GetOrder(ctx, "123")
 	if err != nil {
 		t.Fatalf("GetOrder failed: %v", err)
 	}
 
-	if got.OrderUID != "123" {
-		t.Fatalf("expected OrderUID 123, got %s", got.OrderUID)
+	if got.Order.OrderUID != "123" {
+		t.Fatalf("expected OrderUID 123, got %s", got.Order.OrderUID)
 	}
 
-	if len(rawGot) == 0 {
+	if len(got.Payload) == 0 {
 		t.Fatalf("expected raw JSON, got empty")
 	}
 }
@@ -90,19 +104,20 @@ func TestStore_GetOrder_NotFound(t *testing.T) {
 	store := newFakeStore()
 	ctx := context.Background()
 
-	_, _, err := store.GetOrder(ctx, "999")
+	_, err := store.GetOrder(ctx, "999")
 	if err == nil {
 		t.Fatalf("expected error for missing order")
 	}
 }
 
-func TestStore_LoadAllOrders(t *testing.T) {
+func TestStore_StreamOrders(t *testing.T) {
 	store := newFakeStore()
 	ctx := context.Background()
 
 	orders := []models.Order{
-		{OrderUID: "1"},
-		{OrderUID: "2"},
+		{OrderUID: "1", CustomerID: "a"},
+		{OrderUID: "2", CustomerID: "b"},
+		{OrderUID: "3", CustomerID: "a"},
 	}
 
 	for _, o := range orders {
@@ -110,13 +125,23 @@ func TestStore_LoadAllOrders(t *testing.T) {
 		store.SaveOrder(ctx, o, raw)
 	}
 
-	all, err := store.LoadAllOrders(ctx, 0)
+	var got int
+	err := store.StreamOrders(ctx, models.OrderFilter{CustomerID: "a"}, func(so models.StoredOrder, err error) error {
+		got++
+		return err
+	})
 	if err != nil {
-		t.Fatalf("LoadAllOrders failed: %v", err)
+		t.Fatalf("StreamOrders failed: %v", err)
 	}
 
-	if len(all) != 2 {
-		t.Fatalf("expected 2 orders, got %d", len(all))
+	if got != 2 {
+		t.Fatalf("expected 2 orders, got %d", got)
+	}
+
+	stop := errors.New("stop")
+	err = store.StreamOrders(ctx, models.OrderFilter{}, func(models.StoredOrder, error) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("callback error must stop the stream, got %v", err)
 	}
 }
 
@@ -151,3 +176,30 @@ func TestStore_SaveBadMessageCalled(t *testing.T) {
 		t.Fatalf("error text mismatch")
 	}
 }
+
+func TestOrderFilterQuery(t *testing.T) {
+	q, args := orderFilterQuery(models.OrderFilter{})
+	if len(args) != 0 || strings.Contains(q, "WHERE") || strings.Contains(q, "LIMIT") {
+		t.Fatalf("empty filter must not restrict rows: %s %v", q, args)
+	}
+	if !strings.HasSuffix(q, "ORDER BY date_created, order_uid") {
+		t.Fatalf("unexpected default order: %s", q)
+	}
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q, args = orderFilterQuery(models.OrderFilter{From: from, CustomerID: "c1", Sort: models.SortRecentlySaved, Limit: 1000})
+	if !strings.Contains(q, "WHERE date_created >= $1 AND customer_id = $2 ORDER BY created_at DESC, order_uid LIMIT $3") {
+		t.Fatalf("unexpected query: %s", q)
+	}
+	if len(args) != 3 || args[0] != from || args[1] != "c1" || args[2] != 1000 {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}
+
+func TestCorruptOrderError(t *testing.T) {
+	cause := errors.New("unexpected end of JSON input")
+	var err error = &CorruptOrderError{OrderUID: "123", Err: cause}
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), "123") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
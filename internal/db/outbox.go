@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yourmodule/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// outboxLockKey ключ advisory lock'а relay ("outbox")
+const outboxLockKey int64 = 0x6f7574626f78
+
+// ProcessOrderEvents блокирует до limit самых старых неотправленных событий и
+// передаёт их fn. Если fn отработал без ошибки, события помечаются отправленными
+// в той же транзакции. Возвращает число обработанных событий.
+//
+// Пачку обрабатывает одна реплика за раз: транзакция берёт advisory lock, а
+// остальные реплики, не дождавшись его, возвращают 0. Параллельные пачки
+// (SKIP LOCKED) перемешали бы события одного заказа в Kafka.
+func (s *Store) ProcessOrderEvents(ctx context.Context, limit int, fn func([]models.OrderEvent) error) (n int, err error) {
+	ctx, span := startSpan(ctx, "ProcessOrderEvents", "")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err = tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		span.SetAttributes(attribute.Bool("outbox.busy", true))
+		return 0, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, event_type, order_uid, payload, created_at
+		FROM order_events
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var events []models.OrderEvent
+	ids := make([]int64, 0, limit)
+	for rows.Next() {
+		var e models.OrderEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.OrderUID, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ProcessOrderEvents scan: %w", err)
+		}
+		events = append(events, e)
+		ids = append(ids, e.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ProcessOrderEvents rows: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	span.SetAttributes(attribute.Int("outbox.events", len(events)))
+
+	if fnErr := fn(events); fnErr != nil {
+		// запоминаем неудачную попытку, события остаются в очереди
+		_, err = tx.Exec(ctx, `
+			UPDATE order_events SET attempts = attempts + 1, last_error = $2
+			WHERE id = ANY($1)
+		`, ids, fnErr.Error())
+		if err != nil {
+			return 0, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+		return 0, fnErr
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE order_events SET sent_at = now(), attempts = attempts + 1, last_error = NULL
+		WHERE id = ANY($1)
+	`, ids)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// PurgeSentEvents удаляет отправленные события старше olderThan
+func (s *Store) PurgeSentEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `
+		DELETE FROM order_events
+		WHERE sent_at IS NOT NULL AND sent_at < now() - make_interval(secs => $1)
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"yourmodule/internal/webhook"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Store) CreateWebhook(ctx context.Context, w webhook.Webhook) (webhook.Webhook, error) {
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, event_types, delivery_services)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, w.URL, w.Secret, nonNil(w.EventTypes), nonNil(w.DeliveryServices)).Scan(&w.ID, &w.CreatedAt)
+	return w, err
+}
+
+func (s *Store) ListWebhooks(ctx context.Context) ([]webhook.Webhook, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, url, secret, event_types, delivery_services, created_at
+		FROM webhooks ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []webhook.Webhook
+	for rows.Next() {
+		var w webhook.Webhook
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &w.EventTypes, &w.DeliveryServices, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListWebhooks scan: %w", err)
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) GetWebhook(ctx context.Context, id int64) (webhook.Webhook, error) {
+	var w webhook.Webhook
+	err := s.pool.QueryRow(ctx, `
+		SELECT id, url, secret, event_types, delivery_services, created_at
+		FROM webhooks WHERE id = $1
+	`, id).Scan(&w.ID, &w.URL, &w.Secret, &w.EventTypes, &w.DeliveryServices, &w.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return w, webhook.ErrNotFound
+	}
+	return w, err
+}
+
+// DeleteWebhook удаляет подписку вместе с её журналом доставок
+func (s *Store) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) CreateDelivery(ctx context.Context, d webhook.Delivery) (int64, error) {
+	var id int64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, order_uid, occurred_at, summary, status, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, d.WebhookID, d.EventType, d.OrderUID, d.OccurredAt, d.Summary, d.Status, d.NextAttemptAt).Scan(&id)
+	return id, err
+}
+
+// ClaimDeliveries берёт наступившие доставки, старые первыми. SKIP LOCKED и
+// сдвиг next_attempt_at на lease не дают двум репликам отправить одну доставку.
+func (s *Store) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	rows, err := s.pool.Query(ctx, `
+		UPDATE webhook_deliveries SET next_attempt_at = now() + $2::interval
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`
+	`, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func (s *Store) UpdateDelivery(ctx context.Context, d webhook.Delivery) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_code = NULLIF($4, 0), error = NULLIF($5, ''),
+		    next_attempt_at = $6, updated_at = now()
+		WHERE id = $1
+	`, d.ID, d.Status, d.Attempts, d.ResponseCode, d.Error, d.NextAttemptAt)
+	return err
+}
+
+// ListDeliveries журнал доставок webhook'а, новые первыми
+func (s *Store) ListDeliveries(ctx context.Context, webhookID int64, f webhook.DeliveryFilter) ([]webhook.Delivery, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, webhookID, string(f.Status), f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+const deliveryColumns = `id, webhook_id, event_type, order_uid, occurred_at, summary, status, attempts,
+		       COALESCE(response_code, 0), COALESCE(error, ''), next_attempt_at, created_at, updated_at`
+
+func scanDeliveries(rows pgx.Rows) ([]webhook.Delivery, error) {
+	defer rows.Close()
+
+	var out []webhook.Delivery
+	for rows.Next() {
+		var d webhook.Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.OrderUID, &d.OccurredAt, &d.Summary,
+			&d.Status, &d.Attempts, &d.ResponseCode, &d.Error, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// nonNil пустой массив вместо NULL для колонок NOT NULL
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,112 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// BareVersion версия схемы сообщений без конверта (голый models.Order)
+const BareVersion = 1
+
+// OrderType значение поля type для заказов
+const OrderType = "order"
+
+// Envelope конверт сообщения {schema_version, type, payload}
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Upcaster переводит payload из версии N в версию N+1
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+var (
+	ErrUnknownType    = errors.New("unknown message type")
+	ErrFutureVersion  = errors.New("schema version is newer than supported")
+	ErrMissingUpcast  = errors.New("no upcaster registered")
+	ErrInvalidVersion = errors.New("invalid schema version")
+)
+
+// Registry цепочка upcaster'ов до текущей версии модели
+type Registry struct {
+	current int
+
+	mu        sync.RWMutex
+	upcasters map[int]Upcaster
+}
+
+// NewRegistry создаёт реестр; current — версия, которую понимает models.Order
+func NewRegistry(current int) *Registry {
+	return &Registry{current: current, upcasters: make(map[int]Upcaster)}
+}
+
+// Current текущая версия схемы
+func (r *Registry) Current() int { return r.current }
+
+// Register добавляет upcaster из версии from в from+1
+func (r *Registry) Register(from int, up Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.upcasters[from] = up
+}
+
+// Decode разворачивает конверт (если он есть) и приводит payload к текущей
+// версии. Сообщения без конверта считаются версией BareVersion.
+// Возвращает payload текущей версии и исходную версию сообщения.
+func (r *Registry) Decode(raw []byte) (json.RawMessage, int, error) {
+	env, ok, err := parse(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		env = Envelope{SchemaVersion: BareVersion, Type: OrderType, Payload: raw}
+	}
+
+	if env.Type != "" && env.Type != OrderType {
+		return nil, env.SchemaVersion, fmt.Errorf("%w: %q", ErrUnknownType, env.Type)
+	}
+	if env.SchemaVersion < 1 {
+		return nil, env.SchemaVersion, fmt.Errorf("%w: %d", ErrInvalidVersion, env.SchemaVersion)
+	}
+	if env.SchemaVersion > r.current {
+		return nil, env.SchemaVersion, fmt.Errorf("%w: %d > %d", ErrFutureVersion, env.SchemaVersion, r.current)
+	}
+
+	payload := env.Payload
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for v := env.SchemaVersion; v < r.current; v++ {
+		up, ok := r.upcasters[v]
+		if !ok {
+			return nil, env.SchemaVersion, fmt.Errorf("%w: v%d -> v%d", ErrMissingUpcast, v, v+1)
+		}
+		payload, err = up(payload)
+		if err != nil {
+			return nil, env.SchemaVersion, fmt.Errorf("upcast v%d -> v%d: %w", v, v+1, err)
+		}
+	}
+	return payload, env.SchemaVersion, nil
+}
+
+// parse распознаёт конверт по наличию schema_version и payload
+func parse(raw []byte) (Envelope, bool, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		// не объект — пусть ошибку покажет декодирование заказа
+		return Envelope{}, false, nil
+	}
+	_, hasVersion := probe["schema_version"]
+	_, hasPayload := probe["payload"]
+	if !hasVersion || !hasPayload {
+		return Envelope{}, false, nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return Envelope{}, false, fmt.Errorf("invalid envelope: %w", err)
+	}
+	return env, true, nil
+}
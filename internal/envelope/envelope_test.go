@@ -0,0 +1,90 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// v1 -> v2: поле customer_id переехало в customer.id
+func upcastV1(payload json.RawMessage) (json.RawMessage, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, err
+	}
+	doc["customer"] = map[string]any{"id": doc["customer_id"]}
+	delete(doc, "customer_id")
+	return json.Marshal(doc)
+}
+
+func TestDecode_Bare(t *testing.T) {
+	r := NewRegistry(BareVersion)
+	raw := []byte(`{"order_uid":"1"}`)
+
+	payload, version, err := r.Decode(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != BareVersion || string(payload) != string(raw) {
+		t.Fatalf("bare message must pass through, got v%d %s", version, payload)
+	}
+}
+
+func TestDecode_EnvelopeCurrent(t *testing.T) {
+	r := NewRegistry(1)
+
+	payload, version, err := r.Decode([]byte(`{"schema_version":1,"type":"order","payload":{"order_uid":"1"}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != 1 || string(payload) != `{"order_uid":"1"}` {
+		t.Fatalf("unexpected result: v%d %s", version, payload)
+	}
+}
+
+func TestDecode_Upcast(t *testing.T) {
+	r := NewRegistry(2)
+	r.Register(1, upcastV1)
+
+	for _, raw := range []string{
+		`{"order_uid":"1","customer_id":"c1"}`,
+		`{"schema_version":1,"type":"order","payload":{"order_uid":"1","customer_id":"c1"}}`,
+	} {
+		payload, version, err := r.Decode([]byte(raw))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if version != 1 || string(payload) != `{"customer":{"id":"c1"},"order_uid":"1"}` {
+			t.Fatalf("unexpected upcast result: v%d %s", version, payload)
+		}
+	}
+
+	// v2 уже текущая — без изменений
+	payload, _, err := r.Decode([]byte(`{"schema_version":2,"type":"order","payload":{"customer":{"id":"c2"}}}`))
+	if err != nil || string(payload) != `{"customer":{"id":"c2"}}` {
+		t.Fatalf("current version must pass through: %s %v", payload, err)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	r := NewRegistry(2)
+
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{`{"schema_version":3,"type":"order","payload":{}}`, ErrFutureVersion},
+		{`{"schema_version":1,"type":"order","payload":{}}`, ErrMissingUpcast},
+		{`{"schema_version":0,"type":"order","payload":{}}`, ErrInvalidVersion},
+		{`{"schema_version":2,"type":"refund","payload":{}}`, ErrUnknownType},
+	}
+	for _, tt := range tests {
+		if _, _, err := r.Decode([]byte(tt.raw)); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.raw, tt.want, err)
+		}
+	}
+
+	if _, _, err := r.Decode([]byte(`{"schema_version":"2","payload":{}}`)); err == nil {
+		t.Fatalf("expected error for malformed envelope")
+	}
+}
@@ -0,0 +1,11 @@
+package envelope
+
+// CurrentVersion версия схемы заказа, которую понимает models.Order
+const CurrentVersion = BareVersion
+
+// NewOrderRegistry реестр заказов, общий для consumer и import: оба принимают
+// одни и те же версии. Когда upstream опубликует v2, здесь поднимается
+// CurrentVersion и регистрируется upcaster v1 -> v2.
+func NewOrderRegistry() *Registry {
+	return NewRegistry(CurrentVersion)
+}
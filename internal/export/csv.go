@@ -0,0 +1,53 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"yourmodule/internal/models"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	c := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, col := range columns {
+		c.record[i] = col.name
+	}
+	if err := c.w.Write(c.record); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(so models.StoredOrder) error {
+	return flatten(&so.Order, func(cells []any) error {
+		for i, v := range cells {
+			switch v := v.(type) {
+			case int64:
+				c.record[i] = strconv.FormatInt(v, 10)
+			case string:
+				c.record[i] = escapeFormula(v)
+			}
+		}
+		return c.w.Write(c.record)
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula не даёт табличным редакторам выполнить строку из заказа как формулу
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
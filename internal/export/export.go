@@ -0,0 +1,126 @@
+// Package export выгружает заказы в NDJSON, CSV и XLSX потоком,
+// не держа выборку в памяти
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yourmodule/internal/models"
+)
+
+// Format формат выгрузки
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+)
+
+// ErrUnknownFormat неизвестный формат выгрузки
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// ParseFormat разбирает формат; пустая строка — NDJSON
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatNDJSON, nil
+	case FormatNDJSON, FormatCSV, FormatXLSX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w %q, want ndjson, csv or xlsx", ErrUnknownFormat, s)
+	}
+}
+
+// FormatFromPath угадывает формат по расширению файла
+func FormatFromPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	case ".csv":
+		return FormatCSV, true
+	case ".xlsx":
+		return FormatXLSX, true
+	}
+	return "", false
+}
+
+// ContentType MIME-тип для HTTP-ответа
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Writer пишет заказы в выбранном формате. Close дописывает хвост файла
+// (для XLSX без него файл не откроется), но не закрывает нижележащий io.Writer.
+type Writer interface {
+	Write(so models.StoredOrder) error
+	Close() error
+}
+
+// NewWriter создаёт Writer формата f поверх w
+func NewWriter(f Format, w io.Writer) (Writer, error) {
+	switch f {
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w)}, nil
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, f)
+	}
+}
+
+// ParseTime разбирает границу периода: дату 2006-01-02 (полночь UTC) или RFC 3339
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want 2006-01-02 or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// ndjsonWriter заказ на строку. Сохранённый payload отдаётся как есть (со всеми
+// неизвестными сервису полями), только без переводов строк внутри.
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+}
+
+func (n *ndjsonWriter) Write(so models.StoredOrder) error {
+	n.buf.Reset()
+	if len(so.Payload) > 0 {
+		if err := json.Compact(&n.buf, so.Payload); err != nil {
+			return fmt.Errorf("order %s: %w", so.Order.OrderUID, err)
+		}
+	} else {
+		raw, err := json.Marshal(so.Order)
+		if err != nil {
+			return fmt.Errorf("order %s: %w", so.Order.OrderUID, err)
+		}
+		n.buf.Write(raw)
+	}
+	n.buf.WriteByte('\n')
+	_, err := n.w.Write(n.buf.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) Close() error { return n.w.Flush() }
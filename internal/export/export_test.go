@@ -0,0 +1,196 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"yourmodule/internal/models"
+)
+
+func testOrder() models.StoredOrder {
+	return models.StoredOrder{Order: models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		DeliveryService: "meest",
+		DateCreated:     "2021-11-26T06:22:19Z",
+		Delivery:        models.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin"},
+		Payment:         models.Payment{Amount: 1817, Currency: "USD"},
+		Items: []models.Item{
+			{ChrtID: 9934930, Name: "Mascaras", Price: 453},
+			{ChrtID: 9934931, Name: "=HYPERLINK(\"x\")", Price: 1},
+		},
+	}}
+}
+
+func render(t *testing.T, f Format, orders ...models.StoredOrder) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(f, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, so := range orders {
+		if err := w.Write(so); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNDJSON(t *testing.T) {
+	stored := testOrder()
+	stored.Payload = []byte("{\n  \"order_uid\": \"b563feb7b2b84b6test\",\n  \"loyalty_tier\": \"gold\"\n}")
+	bare := testOrder()
+	bare.Order.OrderUID = "second"
+
+	lines := strings.Split(strings.TrimSuffix(string(render(t, FormatNDJSON, stored, bare)), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	// сохранённый payload идёт как есть, но в одну строку
+	if lines[0] != `{"order_uid":"b563feb7b2b84b6test","loyalty_tier":"gold"}` {
+		t.Fatalf("unexpected first line: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"order_uid":"second"`) {
+		t.Fatalf("order without payload must be encoded from model: %s", lines[1])
+	}
+}
+
+func TestCSV(t *testing.T) {
+	empty := testOrder()
+	empty.Order.OrderUID = "no-items"
+	empty.Order.Items = nil
+
+	records, err := csv.NewReader(bytes.NewReader(render(t, FormatCSV, testOrder(), empty))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d", len(records))
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[name] = i
+	}
+
+	first := records[1]
+	if first[col["order_uid"]] != "b563feb7b2b84b6test" || first[col["item_name"]] != "Mascaras" ||
+		first[col["payment_amount"]] != "1817" || first[col["item_price"]] != "453" {
+		t.Fatalf("unexpected first row: %v", first)
+	}
+	if got := records[2][col["item_name"]]; got != `'=HYPERLINK("x")` {
+		t.Fatalf("formula must be escaped, got %s", got)
+	}
+	if got := records[3]; got[col["order_uid"]] != "no-items" || got[col["item_chrt_id"]] != "" {
+		t.Fatalf("order without items must be one row with empty item columns: %v", got)
+	}
+}
+
+// sheetCell ячейка листа, как её читает тест
+type sheetCell struct {
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+func TestXLSX(t *testing.T) {
+	data := render(t, FormatXLSX, testOrder())
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet io.ReadCloser
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			if sheet, err = f.Open(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if !names[name] {
+			t.Fatalf("missing part %s", name)
+		}
+	}
+	if sheet == nil {
+		t.Fatal("missing sheet")
+	}
+	defer sheet.Close()
+
+	var ws struct {
+		Rows []struct {
+			Cells []sheetCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.NewDecoder(sheet).Decode(&ws); err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Rows) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d", len(ws.Rows))
+	}
+	if ws.Rows[0].Cells[0].Inline != "order_uid" {
+		t.Fatalf("unexpected header: %+v", ws.Rows[0].Cells[0])
+	}
+	// строки inline, числа — числами, формулы остаются текстом
+	row := ws.Rows[2].Cells
+	if row[0].Type != "inlineStr" || row[0].Inline != "b563feb7b2b84b6test" {
+		t.Fatalf("unexpected uid cell: %+v", row[0])
+	}
+	idx := map[string]int{}
+	for i, c := range columns {
+		idx[c.name] = i
+	}
+	if c := row[idx["item_price"]]; c.Type != "" || c.Value != "1" {
+		t.Fatalf("price must be numeric: %+v", c)
+	}
+	if c := row[idx["item_name"]]; c.Inline != `=HYPERLINK("x")` {
+		t.Fatalf("unexpected name cell: %+v", c)
+	}
+}
+
+func TestXLSX_TooManyRows(t *testing.T) {
+	w, err := NewWriter(FormatXLSX, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.(*xlsxWriter).rows = xlsxMaxRows - 1
+	if err := w.Write(testOrder()); !errors.Is(err, ErrTooManyRows) {
+		t.Fatalf("expected ErrTooManyRows, got %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatNDJSON {
+		t.Fatalf("empty format must default to ndjson, got %q %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+	if f, ok := FormatFromPath("dump/2026-01.XLSX"); !ok || f != FormatXLSX {
+		t.Fatalf("expected xlsx from extension, got %q", f)
+	}
+
+	got, err := ParseTime("2026-01-01")
+	if err != nil || !got.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected date: %v %v", got, err)
+	}
+	if _, err := ParseTime("2026-01-01T03:00:00+03:00"); err != nil {
+		t.Fatalf("RFC 3339 must be accepted: %v", err)
+	}
+	if _, err := ParseTime("01.01.2026"); err == nil {
+		t.Fatalf("expected error for unknown layout")
+	}
+}
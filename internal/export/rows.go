@@ -0,0 +1,95 @@
+package export
+
+import "yourmodule/internal/models"
+
+// column колонка плоской таблицы: строка на позицию заказа.
+// value возвращает string или int64, чтобы XLSX мог записать число числом.
+type column struct {
+	name  string
+	value func(o *models.Order, it *models.Item) any
+}
+
+// columns порядок колонок CSV и XLSX. Поля заказа повторяются в каждой строке,
+// у заказа без позиций одна строка с пустыми колонками item_*.
+var columns = []column{
+	{"order_uid", func(o *models.Order, _ *models.Item) any { return o.OrderUID }},
+	{"track_number", func(o *models.Order, _ *models.Item) any { return o.TrackNumber }},
+	{"entry", func(o *models.Order, _ *models.Item) any { return o.Entry }},
+	{"locale", func(o *models.Order, _ *models.Item) any { return o.Locale }},
+	{"customer_id", func(o *models.Order, _ *models.Item) any { return o.CustomerID }},
+	{"delivery_service", func(o *models.Order, _ *models.Item) any { return o.DeliveryService }},
+	{"shardkey", func(o *models.Order, _ *models.Item) any { return o.ShardKey }},
+	{"sm_id", func(o *models.Order, _ *models.Item) any { return int64(o.SmID) }},
+	{"date_created", func(o *models.Order, _ *models.Item) any { return o.DateCreated }},
+	{"oof_shard", func(o *models.Order, _ *models.Item) any { return o.OofShard }},
+
+	{"delivery_name", func(o *models.Order, _ *models.Item) any { return o.Delivery.Name }},
+	{"delivery_phone", func(o *models.Order, _ *models.Item) any { return o.Delivery.Phone }},
+	{"delivery_zip", func(o *models.Order, _ *models.Item) any { return o.Delivery.Zip }},
+	{"delivery_city", func(o *models.Order, _ *models.Item) any { return o.Delivery.City }},
+	{"delivery_address", func(o *models.Order, _ *models.Item) any { return o.Delivery.Address }},
+	{"delivery_region", func(o *models.Order, _ *models.Item) any { return o.Delivery.Region }},
+	{"delivery_email", func(o *models.Order, _ *models.Item) any { return o.Delivery.Email }},
+
+	{"payment_transaction", func(o *models.Order, _ *models.Item) any { return o.Payment.Transaction }},
+	{"payment_request_id", func(o *models.Order, _ *models.Item) any { return o.Payment.RequestID }},
+	{"payment_currency", func(o *models.Order, _ *models.Item) any { return o.Payment.Currency }},
+	{"payment_provider", func(o *models.Order, _ *models.Item) any { return o.Payment.Provider }},
+	{"payment_amount", func(o *models.Order, _ *models.Item) any { return int64(o.Payment.Amount) }},
+	{"payment_dt", func(o *models.Order, _ *models.Item) any { return o.Payment.PaymentDT }},
+	{"payment_bank", func(o *models.Order, _ *models.Item) any { return o.Payment.Bank }},
+	{"payment_delivery_cost", func(o *models.Order, _ *models.Item) any { return int64(o.Payment.DeliveryCost) }},
+	{"payment_goods_total", func(o *models.Order, _ *models.Item) any { return int64(o.Payment.GoodsTotal) }},
+	{"payment_custom_fee", func(o *models.Order, _ *models.Item) any { return int64(o.Payment.CustomFee) }},
+
+	{"item_chrt_id", itemNum(func(it *models.Item) int { return it.ChrtID })},
+	{"item_track_number", itemStr(func(it *models.Item) string { return it.TrackNumber })},
+	{"item_price", itemNum(func(it *models.Item) int { return it.Price })},
+	{"item_rid", itemStr(func(it *models.Item) string { return it.RID })},
+	{"item_name", itemStr(func(it *models.Item) string { return it.Name })},
+	{"item_sale", itemNum(func(it *models.Item) int { return it.Sale })},
+	{"item_size", itemStr(func(it *models.Item) string { return it.Size })},
+	{"item_total_price", itemNum(func(it *models.Item) int { return it.TotalPrice })},
+	{"item_nm_id", itemNum(func(it *models.Item) int { return it.NmID })},
+	{"item_brand", itemStr(func(it *models.Item) string { return it.Brand })},
+	{"item_status", itemNum(func(it *models.Item) int { return it.Status })},
+}
+
+// itemStr, itemNum колонки позиции; без позиции значение пустое
+func itemStr(get func(*models.Item) string) func(*models.Order, *models.Item) any {
+	return func(_ *models.Order, it *models.Item) any {
+		if it == nil {
+			return ""
+		}
+		return get(it)
+	}
+}
+
+func itemNum(get func(*models.Item) int) func(*models.Order, *models.Item) any {
+	return func(_ *models.Order, it *models.Item) any {
+		if it == nil {
+			return ""
+		}
+		return int64(get(it))
+	}
+}
+
+// flatten раскладывает заказ в строки таблицы, по одной на позицию
+func flatten(o *models.Order, row func(cells []any) error) error {
+	cells := make([]any, len(columns))
+	fill := func(it *models.Item) error {
+		for i, c := range columns {
+			cells[i] = c.value(o, it)
+		}
+		return row(cells)
+	}
+	if len(o.Items) == 0 {
+		return fill(nil)
+	}
+	for i := range o.Items {
+		if err := fill(&o.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package importer загружает заказы из файлов в обход Kafka: для дозаливки
+// исторических заказов
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"yourmodule/internal/envelope"
+	"yourmodule/internal/models"
+	"yourmodule/internal/records"
+)
+
+// progressEvery как часто писать в лог прогресс
+const progressEvery = 10000
+
+// Store куда сохраняются заказы
+type Store interface {
+	// SaveOrder возвращает true, если заказ новый
+	SaveOrder(ctx context.Context, ord models.Order, raw []byte) (created bool, savedAt time.Time, err error)
+}
+
+// Importer прогоняет заказы из файла через валидацию и SaveOrder
+type Importer struct {
+	store   Store
+	workers int
+	dryRun  bool
+	strict  bool
+	schemas *envelope.Registry
+}
+
+// Option настраивает Importer
+type Option func(*Importer)
+
+// WithWorkers сколько заказов сохраняется параллельно
+func WithWorkers(n int) Option {
+	return func(im *Importer) {
+		if n > 0 {
+			im.workers = n
+		}
+	}
+}
+
+// WithDryRun только проверяет заказы, ничего не сохраняя
+func WithDryRun(dry bool) Option {
+	return func(im *Importer) { im.dryRun = dry }
+}
+
+// WithStrictFields отклоняет заказы с неизвестными модели полями
+func WithStrictFields(strict bool) Option {
+	return func(im *Importer) { im.strict = strict }
+}
+
+// WithSchemaRegistry задаёт реестр версий схемы, как у consumer
+func WithSchemaRegistry(r *envelope.Registry) Option {
+	return func(im *Importer) { im.schemas = r }
+}
+
+func New(store Store, opts ...Option) *Importer {
+	im := &Importer{store: store, workers: 4, schemas: envelope.NewOrderRegistry()}
+	for _, opt := range opts {
+		opt(im)
+	}
+	return im
+}
+
+// Rejection отклонённый заказ
+type Rejection struct {
+	File string `json:"file"`
+	// Record номер строки NDJSON или элемента массива, с 1
+	Record   int    `json:"record"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// Report итог импорта
+type Report struct {
+	DryRun   bool `json:"dry_run"`
+	Records  int  `json:"records"`
+	Accepted int  `json:"accepted"`
+	// Created, Updated новые и уже существовавшие заказы; в dry run не считаются
+	Created    int         `json:"created"`
+	Updated    int         `json:"updated"`
+	Rejected   int         `json:"rejected"`
+	Rejections []Rejection `json:"rejections"`
+}
+
+// Add добавляет итог другого файла
+func (r *Report) Add(o Report) {
+	r.DryRun = r.DryRun || o.DryRun
+	r.Records += o.Records
+	r.Accepted += o.Accepted
+	r.Created += o.Created
+	r.Updated += o.Updated
+	r.Rejected += o.Rejected
+	r.Rejections = append(r.Rejections, o.Rejections...)
+}
+
+// WriteText печатает итог для человека
+func (r Report) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if r.DryRun {
+		fmt.Fprintln(bw, "dry run, nothing saved")
+	}
+	fmt.Fprintf(bw, "records: %d, accepted: %d (created %d, updated %d), rejected: %d\n",
+		r.Records, r.Accepted, r.Created, r.Updated, r.Rejected)
+	for _, rej := range r.Rejections {
+		uid := rej.OrderUID
+		if uid == "" {
+			uid = "-"
+		}
+		fmt.Fprintf(bw, "%s:%d\t%s\t%s\n", rej.File, rej.Record, uid, rej.Reason)
+	}
+	return bw.Flush()
+}
+
+// job заказ из файла; uid известен, только если JSON разобрался
+type job struct {
+	record int
+	// raw payload без конверта, приведённый к текущей версии
+	raw    []byte
+	ord    models.Order
+	reason string
+}
+
+type result struct {
+	record  int
+	uid     string
+	reason  string
+	created bool
+}
+
+// Import читает NDJSON или JSON-массив заказов (возможно, gzip) и сохраняет их.
+// Заказы с одним order_uid обрабатываются одним воркером по порядку, поэтому
+// при повторах в файле в БД остаётся последняя версия.
+// Ошибка возвращается, только если файл не дочитан; отклонённые заказы — в отчёте.
+func (im *Importer) Import(ctx context.Context, name string, r io.Reader) (Report, error) {
+	rep := Report{DryRun: im.dryRun}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queues := make([]chan job, im.workers)
+	results := make(chan result, im.workers*2)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan job, 16)
+		wg.Add(1)
+		go func(q <-chan job) {
+			defer wg.Done()
+			for j := range q {
+				// после отмены дочитываем очередь, ничего не сохраняя
+				if ctx.Err() == nil {
+					results <- im.process(ctx, j)
+				}
+			}
+		}(queues[i])
+	}
+
+	// читаем в отдельной горутине, чтобы результаты собирались параллельно
+	readErr := make(chan error, 1)
+	go func() {
+		defer func() {
+			for _, q := range queues {
+				close(q)
+			}
+		}()
+		readErr <- records.Read(r, func(n int, raw []byte) error {
+			j := im.decode(n, raw)
+			select {
+			case queues[shard(j.ord.OrderUID, len(queues))] <- j:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		rep.Records++
+		switch {
+		case res.reason != "":
+			rep.Rejected++
+			rep.Rejections = append(rep.Rejections, Rejection{File: name, Record: res.record, OrderUID: res.uid, Reason: res.reason})
+		case im.dryRun:
+			rep.Accepted++
+		case res.created:
+			rep.Accepted++
+			rep.Created++
+		default:
+			rep.Accepted++
+			rep.Updated++
+		}
+		if rep.Records%progressEvery == 0 {
+			slog.InfoContext(ctx, "import progress", "file", name, "records", rep.Records, "rejected", rep.Rejected)
+		}
+	}
+	sort.Slice(rep.Rejections, func(i, j int) bool { return rep.Rejections[i].Record < rep.Rejections[j].Record })
+
+	if err := <-readErr; err != nil {
+		return rep, fmt.Errorf("%s: %w", name, err)
+	}
+	return rep, ctx.Err()
+}
+
+// decode разбирает запись тем же путём, что consumer: конверт снимается,
+// payload приводится к текущей версии схемы
+func (im *Importer) decode(n int, raw []byte) job {
+	j := job{record: n}
+	payload, _, err := im.schemas.Decode(raw)
+	if err != nil {
+		j.reason = "envelope: " + err.Error()
+		return j
+	}
+	j.raw = payload
+	if err := json.Unmarshal(payload, &j.ord); err != nil {
+		j.reason = "json_unmarshal: " + err.Error()
+	}
+	return j
+}
+
+// process проверяет и сохраняет один заказ
+func (im *Importer) process(ctx context.Context, j job) result {
+	res := result{record: j.record, uid: j.ord.OrderUID}
+	if j.reason != "" {
+		res.reason = j.reason
+		return res
+	}
+	validate := j.ord.Validate
+	if im.strict {
+		validate = j.ord.ValidateStrict
+	}
+	if err := validate(); err != nil {
+		// ошибки валидатора многострочные, а отчёт построчный
+		res.reason = "validation: " + strings.ReplaceAll(err.Error(), "\n", "; ")
+		return res
+	}
+	if im.dryRun {
+		return res
+	}
+	created, _, err := im.store.SaveOrder(ctx, j.ord, j.raw)
+	if err != nil {
+		res.reason = "save: " + err.Error()
+		return res
+	}
+	res.created = created
+	return res
+}
+
+// shard номер воркера для заказа
+func shard(uid string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	return int(h.Sum32() % uint32(n))
+}
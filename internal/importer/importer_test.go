@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yourmodule/internal/models"
+)
+
+type fakeStore struct {
+	mu    sync.Mutex
+	saved map[string][]byte
+	fail  string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{saved: map[string][]byte{}}
+}
+
+func (f *fakeStore) SaveOrder(ctx context.Context, ord models.Order, raw []byte) (bool, time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ord.OrderUID == f.fail {
+		return false, time.Time{}, errors.New("db down")
+	}
+	_, exists := f.saved[ord.OrderUID]
+	f.saved[ord.OrderUID] = raw
+	return !exists, time.Now(), nil
+}
+
+func validOrder(uid string) models.Order {
+	return models.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OofShard:        "1",
+	}
+}
+
+func orderJSON(t *testing.T, o models.Order) string {
+	t.Helper()
+	raw, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestImport_NDJSON(t *testing.T) {
+	invalid := validOrder("bad")
+	invalid.Payment.Currency = "EUR"
+	updated := validOrder("a")
+	updated.TrackNumber = "SECOND"
+
+	input := strings.Join([]string{
+		orderJSON(t, validOrder("a")),
+		"",
+		`{not json}`,
+		orderJSON(t, invalid),
+		orderJSON(t, validOrder("b")),
+		orderJSON(t, updated),
+	}, "\n")
+
+	store := newFakeStore()
+	rep, err := New(store, WithWorkers(3)).Import(context.Background(), "orders.ndjson", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Records != 5 || rep.Accepted != 3 || rep.Created != 2 || rep.Updated != 1 || rep.Rejected != 2 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	// номера — строки файла, с учётом пустой
+	if rep.Rejections[0].Record != 3 || !strings.HasPrefix(rep.Rejections[0].Reason, "json_unmarshal:") {
+		t.Fatalf("unexpected first rejection %+v", rep.Rejections[0])
+	}
+	if r := rep.Rejections[1]; r.Record != 4 || r.OrderUID != "bad" || !strings.HasPrefix(r.Reason, "validation:") {
+		t.Fatalf("unexpected second rejection %+v", r)
+	}
+	// повтор uid обрабатывается после первой версии
+	if !strings.Contains(string(store.saved["a"]), "SECOND") {
+		t.Fatalf("last version of a repeated order must win: %s", store.saved["a"])
+	}
+}
+
+func TestImport_Envelope(t *testing.T) {
+	wrapped := validOrder("wrapped")
+	input := strings.Join([]string{
+		orderJSON(t, validOrder("bare")),
+		`{"schema_version":1,"type":"order","payload":` + orderJSON(t, wrapped) + `}`,
+		`{"schema_version":2,"type":"order","payload":` + orderJSON(t, validOrder("future")) + `}`,
+	}, "\n")
+
+	store := newFakeStore()
+	rep, err := New(store).Import(context.Background(), "orders.ndjson", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// версии те же, что принимает consumer
+	if rep.Accepted != 2 || rep.Rejected != 1 || !strings.HasPrefix(rep.Rejections[0].Reason, "envelope:") {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	// в БД уходит payload без конверта
+	if string(store.saved["wrapped"]) != orderJSON(t, wrapped) {
+		t.Fatalf("saved payload must be unwrapped: %s", store.saved["wrapped"])
+	}
+}
+
+func TestImport_GzipArray(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("\n [" + orderJSON(t, validOrder("a")) + ",\n" + orderJSON(t, validOrder("b")) + "]\n"))
+	zw.Close()
+
+	store := newFakeStore()
+	store.fail = "b"
+	rep, err := New(store).Import(context.Background(), "orders.json.gz", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Records != 2 || rep.Accepted != 1 || rep.Rejected != 1 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	if r := rep.Rejections[0]; r.Record != 2 || r.Reason != "save: db down" {
+		t.Fatalf("unexpected rejection %+v", r)
+	}
+}
+
+func TestImport_DryRunStrict(t *testing.T) {
+	raw := orderJSON(t, validOrder("a"))
+	extra := raw[:len(raw)-1] + `,"loyalty_tier":"gold"}`
+
+	store := newFakeStore()
+	rep, err := New(store, WithDryRun(true), WithStrictFields(true)).
+		Import(context.Background(), "-", strings.NewReader(raw+"\n"+extra+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.saved) != 0 {
+		t.Fatalf("dry run must not save anything")
+	}
+	if !rep.DryRun || rep.Accepted != 1 || rep.Created != 0 || rep.Rejected != 1 ||
+		!strings.Contains(rep.Rejections[0].Reason, "loyalty_tier") {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+
+	var out bytes.Buffer
+	if err := rep.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "dry run") || !strings.Contains(out.String(), "-:2\ta\tvalidation:") {
+		t.Fatalf("unexpected text report:\n%s", out.String())
+	}
+}
+
+func TestImport_BrokenArray(t *testing.T) {
+	input := "[" + orderJSON(t, validOrder("a")) + ", {broken"
+	rep, err := New(newFakeStore()).Import(context.Background(), "orders.json", strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("expected error pointing at record 2, got %v", err)
+	}
+	// то, что успели прочитать, всё равно обработано
+	if rep.Accepted != 1 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+}
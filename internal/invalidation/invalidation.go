@@ -0,0 +1,104 @@
+// Package invalidation сбрасывает кэш процесса, когда заказ сохранила любая реплика
+package invalidation
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Event уведомление о сохранённом заказе
+type Event struct {
+	OrderUID string `json:"order_uid"`
+	// Version версия сохранённого заказа (created_at в БД, UnixNano); 0 — неизвестна
+	Version int64 `json:"version"`
+	// Replica процесс, сохранивший заказ
+	Replica string `json:"replica,omitempty"`
+}
+
+// Source поток сохранённых заказов, например LISTEN/NOTIFY Postgres
+type Source interface {
+	// ListenInvalidations вызывает onListen, когда подписка установлена, и fn на
+	// каждый заказ; возвращает ошибку при обрыве, nil — при отмене ctx
+	ListenInvalidations(ctx context.Context, onListen func(), fn func(Event)) error
+}
+
+// Cache кэш процесса, из которого убираются устаревшие заказы
+type Cache interface {
+	// Invalidate убирает заказ версии меньше version и не принимает такие позже
+	Invalidate(key string, version int64)
+	Clear()
+}
+
+// Listener держит подписку и переподключается с бэкоффом
+type Listener struct {
+	src        Source
+	cache      Cache
+	replica    string
+	backoffMin time.Duration
+	backoffMax time.Duration
+}
+
+// Option настраивает Listener
+type Option func(*Listener)
+
+// WithBackoff пауза перед переподключением, удваивается до max
+func WithBackoff(backoffMin, backoffMax time.Duration) Option {
+	return func(l *Listener) {
+		if backoffMin > 0 && backoffMin <= backoffMax {
+			l.backoffMin, l.backoffMax = backoffMin, backoffMax
+		}
+	}
+}
+
+// WithReplica id этого процесса: свои уведомления пропускаются, кэш процесса
+// consumer обновил сам
+func WithReplica(id string) Option {
+	return func(l *Listener) { l.replica = id }
+}
+
+func NewListener(src Source, c Cache, opts ...Option) *Listener {
+	l := &Listener{src: src, cache: c, backoffMin: 500 * time.Millisecond, backoffMax: 10 * time.Second}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Run слушает до отмены ctx. Уведомления, пришедшие во время обрыва, потеряны,
+// поэтому после переподключения кэш процесса сбрасывается целиком.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := l.backoffMin
+	listened := false
+	for {
+		err := l.src.ListenInvalidations(ctx,
+			func() {
+				if listened {
+					l.cache.Clear()
+					slog.InfoContext(ctx, "cache invalidation reconnected, local cache cleared")
+				} else {
+					slog.InfoContext(ctx, "cache invalidation listening")
+				}
+				listened = true
+				backoff = l.backoffMin
+			},
+			func(ev Event) {
+				if l.replica != "" && ev.Replica == l.replica {
+					return
+				}
+				l.cache.Invalidate(ev.OrderUID, ev.Version)
+				slog.DebugContext(ctx, "order evicted from cache", "order_uid", ev.OrderUID, "replica", ev.Replica)
+			},
+		)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WarnContext(ctx, "cache invalidation listener stopped, retrying", "backoff", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, l.backoffMax)
+	}
+}
@@ -0,0 +1,86 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCache struct {
+	mu      sync.Mutex
+	deleted []string
+	clears  int
+}
+
+func (f *fakeCache) Invalidate(key string, version int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, key)
+}
+
+func (f *fakeCache) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clears++
+}
+
+// fakeSource отдаёт по сессии на каждый вызов: сначала ошибка подключения,
+// затем сессии с уведомлениями, обрывающиеся ошибкой
+type fakeSource struct {
+	sessions [][]Event
+	calls    int
+	cancel   context.CancelFunc
+}
+
+func (f *fakeSource) ListenInvalidations(ctx context.Context, onListen func(), fn func(Event)) error {
+	f.calls++
+	if f.calls == 1 {
+		return errors.New("connection refused")
+	}
+	if len(f.sessions) == 0 {
+		f.cancel()
+		<-ctx.Done()
+		return nil
+	}
+	s := f.sessions[0]
+	f.sessions = f.sessions[1:]
+	onListen()
+	for _, ev := range s {
+		fn(ev)
+	}
+	return errors.New("conn closed")
+}
+
+func TestListener_EvictsAndClearsOnReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &fakeSource{sessions: [][]Event{
+		{{OrderUID: "a", Replica: "r2"}, {OrderUID: "b", Replica: "r2"}, {OrderUID: "own", Replica: "r1"}},
+		{{OrderUID: "c"}},
+	}, cancel: cancel}
+	c := &fakeCache{}
+
+	done := make(chan struct{})
+	go func() {
+		NewListener(src, c, WithReplica("r1"), WithBackoff(time.Millisecond, 2*time.Millisecond)).Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop after cancel")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// свои уведомления пропускаются
+	if len(c.deleted) != 3 || c.deleted[0] != "a" || c.deleted[2] != "c" {
+		t.Fatalf("unexpected evictions %v", c.deleted)
+	}
+	// неудачное подключение до первой подписки кэш не сбрасывает, переподключение — сбрасывает
+	if c.clears != 1 {
+		t.Fatalf("expected one clear after reconnect, got %d", c.clears)
+	}
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	lvl, err := ParseLevel("WARN")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lvl != slog.LevelWarn {
+		t.Fatalf("expected warn, got %v", lvl)
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
+
+func TestNew_ContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := With(context.Background(), "request_id", "req-1")
+	ctx = With(ctx, "order_uid", "123")
+	logger.InfoContext(ctx, "hello")
+	logger.DebugContext(ctx, "hidden")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected single JSON record, got %q: %v", buf.String(), err)
+	}
+	if rec["request_id"] != "req-1" || rec["order_uid"] != "123" {
+		t.Fatalf("context attrs missing: %v", rec)
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Типы событий заказа
+const (
+	EventOrderCreated = "order.created"
+	EventOrderUpdated = "order.updated"
+)
+
+// OrderEvent событие изменения заказа из таблицы order_events (outbox)
+type OrderEvent struct {
+	ID       int64
+	Type     string
+	OrderUID string
+	// Payload заказ в том виде, в каком он сохранён
+	Payload   []byte
+	CreatedAt time.Time
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Extra поля сообщения, неизвестные модели. Сохраняются при декодировании
+// и возвращаются при кодировании, чтобы не терять данные upstream.
+type Extra map[string]json.RawMessage
+
+// UnknownFieldsError ошибка строгой валидации: в заказе есть неизвестные поля
+type UnknownFieldsError struct {
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return "unknown fields: " + strings.Join(e.Fields, ", ")
+}
+
+var knownFieldsCache sync.Map // reflect.Type -> map[string]bool
+
+// knownFields имена json-полей типа в нижнем регистре:
+// encoding/json сопоставляет ключи без учёта регистра
+func knownFields(t reflect.Type) map[string]bool {
+	if v, ok := knownFieldsCache.Load(t); ok {
+		return v.(map[string]bool)
+	}
+	out := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out[strings.ToLower(name)] = true
+	}
+	knownFieldsCache.Store(t, out)
+	return out
+}
+
+// decodeWithExtra декодирует data в dst (указатель на тип без методов JSON)
+// и возвращает поля, которых нет в dst
+func decodeWithExtra(data []byte, dst any) (Extra, error) {
+	if err := json.Unmarshal(data, dst); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := knownFields(reflect.TypeOf(dst).Elem())
+	var extra Extra
+	for k, v := range all {
+		if known[strings.ToLower(k)] {
+			continue
+		}
+		if extra == nil {
+			extra = make(Extra)
+		}
+		extra[k] = v
+	}
+	return extra, nil
+}
+
+// encodeWithExtra кодирует v и дописывает в объект неизвестные поля
+func encodeWithExtra(v any, extra Extra) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	known := knownFields(reflect.TypeOf(v))
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		// известные поля не перетираем
+		if !known[strings.ToLower(k)] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(data[:len(data)-1]) // без закрывающей }
+	for _, k := range keys {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(k)
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := json.Compact(buf, extra[k]); err != nil {
+			return nil, fmt.Errorf("extra field %q: %w", k, err)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// addUnknown добавляет пути неизвестных полей с префиксом
+func addUnknown(out []string, prefix string, extra Extra) []string {
+	for k := range extra {
+		out = append(out, prefix+k)
+	}
+	return out
+}
+
+func (d *Delivery) UnmarshalJSON(data []byte) error {
+	type plain Delivery
+	var p plain
+	extra, err := decodeWithExtra(data, &p)
+	if err != nil {
+		return err
+	}
+	*d = Delivery(p)
+	d.Extra = extra
+	return nil
+}
+
+func (d Delivery) MarshalJSON() ([]byte, error) {
+	type plain Delivery
+	return encodeWithExtra(plain(d), d.Extra)
+}
+
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	type plain Payment
+	var v plain
+	extra, err := decodeWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*p = Payment(v)
+	p.Extra = extra
+	return nil
+}
+
+func (p Payment) MarshalJSON() ([]byte, error) {
+	type plain Payment
+	return encodeWithExtra(plain(p), p.Extra)
+}
+
+func (i *Item) UnmarshalJSON(data []byte) error {
+	type plain Item
+	var v plain
+	extra, err := decodeWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*i = Item(v)
+	i.Extra = extra
+	return nil
+}
+
+func (i Item) MarshalJSON() ([]byte, error) {
+	type plain Item
+	return encodeWithExtra(plain(i), i.Extra)
+}
+
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order
+	var v plain
+	extra, err := decodeWithExtra(data, &v)
+	if err != nil {
+		return err
+	}
+	*o = Order(v)
+	o.Extra = extra
+	return nil
+}
+
+func (o Order) MarshalJSON() ([]byte, error) {
+	type plain Order
+	return encodeWithExtra(plain(o), o.Extra)
+}
+
+// UnknownFields пути всех неизвестных полей заказа, например delivery.floor
+// или items[0].color
+func (o *Order) UnknownFields() []string {
+	out := addUnknown(nil, "", o.Extra)
+	out = addUnknown(out, "delivery.", o.Delivery.Extra)
+	out = addUnknown(out, "payment.", o.Payment.Extra)
+	for i, it := range o.Items {
+		out = addUnknown(out, fmt.Sprintf("items[%d].", i), it.Extra)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const upstreamJSON = `{
+	"order_uid": "123",
+	"track_number": "WB123",
+	"loyalty": {"tier": "gold"},
+	"delivery": {"name": "Test", "floor": 5},
+	"payment": {"transaction": "tx", "installments": 3},
+	"items": [{"chrt_id": 1, "color": "red"}, {"chrt_id": 2}]
+}`
+
+func TestOrder_ExtraRoundTrip(t *testing.T) {
+	var ord Order
+	if err := json.Unmarshal([]byte(upstreamJSON), &ord); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ord.Extra["loyalty"]) != `{"tier": "gold"}` {
+		t.Fatalf("top-level extra not captured: %v", ord.Extra)
+	}
+	if string(ord.Delivery.Extra["floor"]) != "5" || string(ord.Items[0].Extra["color"]) != `"red"` {
+		t.Fatalf("nested extra not captured")
+	}
+	if ord.Items[1].Extra != nil || ord.Delivery.Name != "Test" {
+		t.Fatalf("unexpected decode result: %+v", ord)
+	}
+
+	data, err := json.Marshal(ord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Order
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("re-encoded order is invalid JSON: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(back.UnknownFields(), ord.UnknownFields()) {
+		t.Fatalf("extra fields lost after round trip: %s", data)
+	}
+
+	var generic map[string]any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic["loyalty"].(map[string]any)["tier"] != "gold" {
+		t.Fatalf("unexpected loyalty: %v", generic["loyalty"])
+	}
+}
+
+func TestOrder_KnownFieldsCaseInsensitive(t *testing.T) {
+	// encoding/json заполняет поле и при другом регистре — это не неизвестное поле
+	var ord Order
+	if err := json.Unmarshal([]byte(`{"Order_UID":"1"}`), &ord); err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderUID != "1" || len(ord.Extra) != 0 {
+		t.Fatalf("unexpected decode: uid=%q extra=%v", ord.OrderUID, ord.Extra)
+	}
+}
+
+func TestOrder_ValidateStrict(t *testing.T) {
+	var ord Order
+	if err := json.Unmarshal([]byte(upstreamJSON), &ord); err != nil {
+		t.Fatal(err)
+	}
+	// делаем заказ валидным по остальным правилам
+	valid := validOrder()
+	valid.Extra = ord.Extra
+	valid.Delivery.Extra = ord.Delivery.Extra
+	valid.Items[0].Extra = ord.Items[0].Extra
+
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("non-strict validation must ignore extra fields: %v", err)
+	}
+
+	err := valid.ValidateStrict()
+	var unknown *UnknownFieldsError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected UnknownFieldsError, got %v", err)
+	}
+	want := []string{"delivery.floor", "items[0].color", "loyalty"}
+	if !reflect.DeepEqual(unknown.Fields, want) {
+		t.Fatalf("expected %v, got %v", want, unknown.Fields)
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// OrderSort порядок выдачи заказов
+type OrderSort int
+
+const (
+	// SortDateCreated по date_created и order_uid, от старых к новым
+	SortDateCreated OrderSort = iota
+	// SortRecentlySaved по времени записи в БД, сначала последние
+	SortRecentlySaved
+)
+
+// OrderFilter отбор заказов для выгрузок и прогрева. Пустые поля не ограничивают выборку.
+type OrderFilter struct {
+	// From, To полуинтервал [From, To) по date_created
+	From time.Time
+	To   time.Time
+
+	DeliveryService string
+	CustomerID      string
+
+	Sort OrderSort
+	// Limit сколько заказов отдать, 0 — все
+	Limit int
+}
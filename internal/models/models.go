@@ -1,6 +1,10 @@
 package models
 
-import "github.com/go-playground/validator/v10"
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
 
 var validate = validator.New()
 
@@ -12,6 +16,7 @@ type Delivery struct {
 	Address string `json:"address" validate:"required,min=1,max=256"`
 	Region  string `json:"region" validate:"required,min=1,max=64"`
 	Email   string `json:"email" validate:"required,email,max=128"`
+	Extra   Extra  `json:"-"`
 }
 
 type Payment struct {
@@ -25,6 +30,7 @@ type Payment struct {
 	DeliveryCost int    `json:"delivery_cost" validate:"gte=0"`
 	GoodsTotal   int    `json:"goods_total" validate:"gte=0"`
 	CustomFee    int    `json:"custom_fee" validate:"gte=0"`
+	Extra        Extra  `json:"-"`
 }
 
 type Item struct {
@@ -39,6 +45,7 @@ type Item struct {
 	NmID        int    `json:"nm_id" validate:"gt=0"`
 	Brand       string `json:"brand" validate:"required,min=1,max=64"`
 	Status      int    `json:"status" validate:"gte=0"`
+	Extra       Extra  `json:"-"`
 }
 
 type Order struct {
@@ -56,8 +63,27 @@ type Order struct {
 	SmID              int      `json:"sm_id" validate:"gte=0"`
 	DateCreated       string   `json:"date_created" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
 	OofShard          string   `json:"oof_shard" validate:"required,min=1,max=32"`
+	Extra             Extra    `json:"-"`
 }
 
 func (o *Order) Validate() error {
 	return validate.Struct(o)
 }
+
+// ValidateStrict как Validate, но дополнительно отклоняет неизвестные поля
+func (o *Order) ValidateStrict() error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	if unknown := o.UnknownFields(); len(unknown) > 0 {
+		return &UnknownFieldsError{Fields: unknown}
+	}
+	return nil
+}
+
+// StoredOrder заказ вместе с исходным payload и временем записи в БД
+type StoredOrder struct {
+	Order     Order
+	Payload   []byte
+	CreatedAt time.Time
+}
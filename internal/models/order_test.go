@@ -5,8 +5,8 @@ import (
 	"time"
 )
 
-func TestOrderValidation_OK(t *testing.T) {
-	ord := Order{
+func validOrder() Order {
+	return Order{
 		OrderUID:    "123",
 		TrackNumber: "WB123",
 		Entry:       "WBIL",
@@ -54,6 +54,10 @@ func TestOrderValidation_OK(t *testing.T) {
 		DateCreated:       time.Now().Format(time.RFC3339),
 		OofShard:          "1",
 	}
+}
+
+func TestOrderValidation_OK(t *testing.T) {
+	ord := validOrder()
 
 	err := validate.Struct(ord)
 	if err != nil {
@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"yourmodule/internal/models"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("yourmodule/internal/outbox")
+
+// Store источник событий outbox
+type Store interface {
+	ProcessOrderEvents(ctx context.Context, limit int, fn func([]models.OrderEvent) error) (int, error)
+	PurgeSentEvents(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// Writer интерфейс для kafka.Writer
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Message тело события в Kafka
+type Message struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OrderUID   string          `json:"order_uid"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Order      json.RawMessage `json:"order"`
+}
+
+// Relay переносит события из order_events в Kafka
+type Relay struct {
+	store  Store
+	writer Writer
+
+	batch     int
+	interval  time.Duration
+	retention time.Duration
+}
+
+// Option настраивает Relay
+type Option func(*Relay)
+
+// WithBatchSize сколько событий публикуется за раз
+func WithBatchSize(n int) Option {
+	return func(r *Relay) { r.batch = n }
+}
+
+// WithInterval пауза между опросами пустой очереди
+func WithInterval(d time.Duration) Option {
+	return func(r *Relay) { r.interval = d }
+}
+
+// WithRetention сколько хранить отправленные события; 0 — не удалять
+func WithRetention(d time.Duration) Option {
+	return func(r *Relay) { r.retention = d }
+}
+
+// NewRelay создаёт Relay
+func NewRelay(store Store, writer Writer, opts ...Option) *Relay {
+	r := &Relay{
+		store:    store,
+		writer:   writer,
+		batch:    100,
+		interval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run публикует события, пока не отменён ctx. Если пачка заполнена целиком,
+// следующая берётся сразу, иначе relay ждёт interval.
+func (r *Relay) Run(ctx context.Context) {
+	slog.InfoContext(ctx, "outbox relay started", "batch", r.batch, "interval", r.interval)
+	lastPurge := time.Now()
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "outbox relay failed", "err", err)
+		}
+
+		if r.retention > 0 && time.Since(lastPurge) > time.Hour {
+			lastPurge = time.Now()
+			if purged, err := r.store.PurgeSentEvents(ctx, r.retention); err != nil {
+				slog.WarnContext(ctx, "outbox purge failed", "err", err)
+			} else if purged > 0 {
+				slog.InfoContext(ctx, "outbox purged", "events", purged)
+			}
+		}
+
+		wait := r.interval
+		if err == nil && n == r.batch {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "outbox relay stopped")
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce публикует одну пачку событий и возвращает их число.
+// Доставка at-least-once: если запись в Kafka прошла, а фиксация в БД нет,
+// события уйдут повторно, поэтому получатели дедуплицируют по id.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "outbox.relay", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	n, err := r.store.ProcessOrderEvents(ctx, r.batch, func(events []models.OrderEvent) error {
+		msgs := make([]kafka.Message, 0, len(events))
+		for _, e := range events {
+			m, err := encode(e)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, m)
+		}
+		return r.writer.WriteMessages(ctx, msgs...)
+	})
+	span.SetAttributes(attribute.Int("outbox.events", n))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "relay failed")
+		return 0, err
+	}
+	if n > 0 {
+		slog.DebugContext(ctx, "outbox events published", "events", n)
+	}
+	return n, nil
+}
+
+// encode собирает сообщение Kafka; ключ — order_uid, чтобы события
+// одного заказа попадали в одну партицию по порядку
+func encode(e models.OrderEvent) (kafka.Message, error) {
+	body, err := json.Marshal(Message{
+		ID:         e.ID,
+		Type:       e.Type,
+		OrderUID:   e.OrderUID,
+		OccurredAt: e.CreatedAt.UTC(),
+		Order:      e.Payload,
+	})
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	return kafka.Message{
+		Key:   []byte(e.OrderUID),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/json")},
+			{Key: "event-type", Value: []byte(e.Type)},
+			{Key: "event-id", Value: []byte(strconv.FormatInt(e.ID, 10))},
+		},
+	}, nil
+}
@@ -0,0 +1,191 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"yourmodule/internal/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// --------- FAKE STORE ---------
+// fakeStore повторяет транзакционную семантику db.Store.ProcessOrderEvents:
+// события помечаются отправленными, только если fn вернул nil
+type fakeStore struct {
+	mu       sync.Mutex
+	events   []models.OrderEvent
+	sent     map[int64]bool
+	attempts map[int64]int
+}
+
+func newFakeStore(events ...models.OrderEvent) *fakeStore {
+	return &fakeStore{events: events, sent: map[int64]bool{}, attempts: map[int64]int{}}
+}
+
+func (f *fakeStore) ProcessOrderEvents(ctx context.Context, limit int, fn func([]models.OrderEvent) error) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var batch []models.OrderEvent
+	for _, e := range f.events {
+		if !f.sent[e.ID] && len(batch) < limit {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	for _, e := range batch {
+		f.attempts[e.ID]++
+	}
+	if err := fn(batch); err != nil {
+		return 0, err
+	}
+	for _, e := range batch {
+		f.sent[e.ID] = true
+	}
+	return len(batch), nil
+}
+
+func (f *fakeStore) sentCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sent)
+}
+
+func (f *fakeStore) PurgeSentEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return 0, nil
+}
+
+// --------- FAKE WRITER ---------
+type fakeWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, msgs...)
+	return nil
+}
+
+func event(id int64, typ, uid string) models.OrderEvent {
+	return models.OrderEvent{
+		ID:        id,
+		Type:      typ,
+		OrderUID:  uid,
+		Payload:   []byte(`{"order_uid":"` + uid + `"}`),
+		CreatedAt: time.Date(2026, 1, 16, 15, 58, 0, 0, time.UTC),
+	}
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestRelayOnce(t *testing.T) {
+	store := newFakeStore(
+		event(1, models.EventOrderCreated, "a"),
+		event(2, models.EventOrderUpdated, "a"),
+		event(3, models.EventOrderCreated, "b"),
+	)
+	w := &fakeWriter{}
+	r := NewRelay(store, w, WithBatchSize(2))
+
+	n, err := r.RelayOnce(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 events, got n=%d err=%v", n, err)
+	}
+	n, err = r.RelayOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 event, got n=%d err=%v", n, err)
+	}
+	if n, _ := r.RelayOnce(context.Background()); n != 0 {
+		t.Fatalf("queue must be drained, got %d", n)
+	}
+
+	if len(w.msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(w.msgs))
+	}
+	m := w.msgs[1]
+	if string(m.Key) != "a" {
+		t.Fatalf("message key must be order_uid, got %q", m.Key)
+	}
+	if header(m, "event-type") != models.EventOrderUpdated || header(m, "event-id") != "2" {
+		t.Fatalf("unexpected headers: %+v", m.Headers)
+	}
+
+	var body Message
+	if err := json.Unmarshal(m.Value, &body); err != nil {
+		t.Fatalf("invalid message body: %v", err)
+	}
+	if body.ID != 2 || body.Type != models.EventOrderUpdated || body.OrderUID != "a" ||
+		string(body.Order) != `{"order_uid":"a"}` || !body.OccurredAt.Equal(store.events[1].CreatedAt) {
+		t.Fatalf("unexpected message body: %+v", body)
+	}
+}
+
+func TestRelayOnce_WriterFailure(t *testing.T) {
+	store := newFakeStore(event(1, models.EventOrderCreated, "a"))
+	w := &fakeWriter{err: errors.New("broker unavailable")}
+	r := NewRelay(store, w)
+
+	if _, err := r.RelayOnce(context.Background()); err == nil {
+		t.Fatalf("expected writer error")
+	}
+	if store.sent[1] {
+		t.Fatalf("event must stay pending after failed publish")
+	}
+
+	// после восстановления брокера событие уходит повторно
+	w.err = nil
+	if n, err := r.RelayOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected retry to publish event, got n=%d err=%v", n, err)
+	}
+	if store.attempts[1] != 2 || len(w.msgs) != 1 {
+		t.Fatalf("expected 2 attempts and 1 message, got attempts=%d msgs=%d", store.attempts[1], len(w.msgs))
+	}
+}
+
+func TestRun_DrainsBacklogAndStops(t *testing.T) {
+	var events []models.OrderEvent
+	for i := int64(1); i <= 25; i++ {
+		events = append(events, event(i, models.EventOrderCreated, "o"))
+	}
+	store := newFakeStore(events...)
+	w := &fakeWriter{}
+	// большой интервал: весь backlog должен уйти без ожидания между полными пачками
+	r := NewRelay(store, w, WithBatchSize(10), WithInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.sentCount() < 25 {
+		if time.Now().After(deadline) {
+			t.Fatalf("backlog not drained, sent %d of 25", store.sentCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("relay did not stop on context cancel")
+	}
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTTL через сколько неактивный клиент забывается
+const idleTTL = 10 * time.Minute
+
+// Rule параметры token bucket: RPS — скорость пополнения, Burst — ёмкость.
+// RPS <= 0 отключает ограничение.
+type Rule struct {
+	RPS   float64
+	Burst int
+}
+
+// ParseRule разбирает правило в формате rps:burst
+func ParseRule(s string) (Rule, error) {
+	rps, burst, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q must look like rps:burst", s)
+	}
+	r, err := strconv.ParseFloat(rps, 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rate limit rps: %w", err)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rate limit burst: %w", err)
+	}
+	if r > 0 && b <= 0 {
+		return Rule{}, fmt.Errorf("rate limit burst must be positive when rps > 0")
+	}
+	return Rule{RPS: r, Burst: b}, nil
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter token bucket на каждого клиента
+type Limiter struct {
+	rule Rule
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New создаёт Limiter с заданным правилом
+func New(rule Rule) *Limiter {
+	return &Limiter{
+		rule:    rule,
+		clients: make(map[string]*client),
+		now:     time.Now,
+	}
+}
+
+// Allow списывает токен клиента. Если токенов нет, возвращает false
+// и время, через которое стоит повторить запрос.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN как Allow, но списывает n токенов разом, для дорогих запросов.
+// n больше Burst урезается до Burst, иначе такой запрос не прошёл бы никогда.
+func (l *Limiter) AllowN(key string, n int) (bool, time.Duration) {
+	if l.rule.RPS <= 0 {
+		return true, 0
+	}
+	n = min(n, l.rule.Burst)
+
+	l.mu.Lock()
+	now := l.now()
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rate.Limit(l.rule.RPS), l.rule.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	l.sweep(now)
+	l.mu.Unlock()
+
+	res := c.limiter.ReserveN(now, n)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Blocked исчерпаны ли токены клиента; в отличие от Allow ничего не списывает
+func (l *Limiter) Blocked(key string) (bool, time.Duration) {
+	if l.rule.RPS <= 0 {
+		return false, 0
+	}
+	l.mu.Lock()
+	c, ok := l.clients[key]
+	now := l.now()
+	l.mu.Unlock()
+	if !ok {
+		return false, 0
+	}
+	if tokens := c.limiter.TokensAt(now); tokens < 1 {
+		return true, time.Duration((1 - tokens) / l.rule.RPS * float64(time.Second))
+	}
+	return false, 0
+}
+
+// sweep удаляет давно неактивных клиентов, чтобы карта не росла бесконечно.
+// Вызывается под l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for k, c := range l.clients {
+		if now.Sub(c.lastSeen) > idleTTL {
+			delete(l.clients, k)
+		}
+	}
+}
+
+// PerRoute набор лимитеров с отдельными правилами для маршрутов
+type PerRoute struct {
+	def   Rule
+	rules map[string]Rule
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// NewPerRoute создаёт лимитеры; для маршрутов без правила действует def
+func NewPerRoute(def Rule, rules map[string]Rule) *PerRoute {
+	return &PerRoute{def: def, rules: rules, limiters: make(map[string]*Limiter)}
+}
+
+// For возвращает лимитер маршрута; у каждого маршрута свои bucket'ы
+func (p *PerRoute) For(route string) *Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.limiters[route]; ok {
+		return l
+	}
+	rule, ok := p.rules[route]
+	if !ok {
+		rule = p.def
+	}
+	l := New(rule)
+	p.limiters[route] = l
+	return l
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	r, err := ParseRule("2.5:10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.RPS != 2.5 || r.Burst != 10 {
+		t.Fatalf("unexpected rule: %+v", r)
+	}
+
+	for _, bad := range []string{"10", "x:1", "1:y", "5:0"} {
+		if _, err := ParseRule(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	l := New(Rule{RPS: 1, Burst: 2})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d must fit into burst", i)
+		}
+	}
+
+	ok, retry := l.Allow("a")
+	if ok {
+		t.Fatalf("expected limit to be exceeded")
+	}
+	if retry <= 0 || retry > time.Second {
+		t.Fatalf("unexpected retry delay: %v", retry)
+	}
+
+	// другой клиент не затронут
+	if ok, _ := l.Allow("b"); !ok {
+		t.Fatalf("clients must have separate buckets")
+	}
+
+	// через секунду появился токен
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatalf("expected token to be refilled")
+	}
+}
+
+func TestLimiter_AllowN(t *testing.T) {
+	l := New(Rule{RPS: 1, Burst: 5})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.AllowN("a", 4); !ok {
+		t.Fatalf("4 tokens must fit into burst of 5")
+	}
+	if ok, _ := l.AllowN("a", 2); ok {
+		t.Fatalf("only one token left, 2 must be rejected")
+	}
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatalf("rejected AllowN must not spend tokens")
+	}
+
+	// больше burst урезается до burst, а не отклоняется навсегда
+	now = now.Add(5 * time.Second)
+	if ok, _ := l.AllowN("a", 100); !ok {
+		t.Fatalf("n above burst must be clamped")
+	}
+}
+
+func TestLimiter_Blocked(t *testing.T) {
+	l := New(Rule{RPS: 1, Burst: 2})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if blocked, _ := l.Blocked("a"); blocked {
+		t.Fatalf("unknown client must not be blocked")
+	}
+	l.Allow("a")
+	if blocked, _ := l.Blocked("a"); blocked {
+		t.Fatalf("client with a token left must not be blocked")
+	}
+	// Blocked не списывает токены
+	if blocked, _ := l.Blocked("a"); blocked {
+		t.Fatalf("Blocked must not spend tokens")
+	}
+	l.Allow("a")
+	blocked, retry := l.Blocked("a")
+	if !blocked || retry <= 0 || retry > time.Second {
+		t.Fatalf("expected block with retry within 1s, got %v %v", blocked, retry)
+	}
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	l := New(Rule{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("disabled limiter must allow everything")
+		}
+	}
+}
+
+func TestLimiter_SweepIdle(t *testing.T) {
+	l := New(Rule{RPS: 1, Burst: 1})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(2 * idleTTL)
+	l.Allow("b")
+
+	if _, ok := l.clients["a"]; ok {
+		t.Fatalf("idle client must be swept")
+	}
+}
+
+func TestPerRoute(t *testing.T) {
+	p := NewPerRoute(Rule{RPS: 1, Burst: 1}, map[string]Rule{"/hot": {RPS: 1, Burst: 3}})
+
+	if p.For("/hot") != p.For("/hot") {
+		t.Fatalf("limiter must be reused for the same route")
+	}
+	if p.For("/hot").rule.Burst != 3 || p.For("/other").rule.Burst != 1 {
+		t.Fatalf("unexpected rules")
+	}
+}
@@ -0,0 +1,98 @@
+// Package records читает файлы заказов: NDJSON или JSON-массив, возможно сжатый gzip.
+// Это формат выгрузки export, его же принимают import и прогрев кэша.
+package records
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxLineSize предел строки NDJSON
+const maxLineSize = 16 << 20
+
+// Read вызывает fn для каждой записи: элемента JSON-массива или строки NDJSON.
+// Формат определяется по первому значащему символу, gzip — по сигнатуре.
+// n — номер записи, с 1; для NDJSON совпадает с номером строки.
+// raw принадлежит fn, его можно передавать в другие горутины.
+func Read(r io.Reader, fn func(n int, raw []byte) error) error {
+	in, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(in)
+	// пропущенные пустые строки учитываем, чтобы номера записей совпадали с номерами строк
+	skipped := 0
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch b[0] {
+		case '\n':
+			skipped++
+			fallthrough
+		case ' ', '\t', '\r':
+			br.ReadByte()
+			continue
+		case '[':
+			return array(br, fn)
+		}
+		return lines(br, skipped, fn)
+	}
+}
+
+// decompress распознаёт gzip по сигнатуре
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+func array(r io.Reader, fn func(n int, raw []byte) error) error {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for n := 1; dec.More(); n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// после синтаксической ошибки массив дальше не разобрать
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := fn(n, raw); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func lines(r io.Reader, skipped int, fn func(n int, raw []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxLineSize)
+	for n := skipped + 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		// буфер сканера переиспользуется
+		if err := fn(n, bytes.Clone(line)); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read NDJSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package records
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type record struct {
+	n   int
+	raw string
+}
+
+func readAll(t *testing.T, input []byte) ([]record, error) {
+	t.Helper()
+	var out []record
+	err := Read(bytes.NewReader(input), func(n int, raw []byte) error {
+		out = append(out, record{n, string(raw)})
+		return nil
+	})
+	return out, err
+}
+
+func TestRead_NDJSON(t *testing.T) {
+	got, err := readAll(t, []byte("\n{\"a\":1}\n\n  {\"b\":2}  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// номера записей — номера строк, пустые строки тоже считаются
+	if len(got) != 2 || got[0] != (record{2, `{"a":1}`}) || got[1] != (record{4, `{"b":2}`}) {
+		t.Fatalf("unexpected records %+v", got)
+	}
+}
+
+func TestRead_GzipArray(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(" [{\"a\":1},\n{\"b\":2}]\n"))
+	zw.Close()
+
+	got, err := readAll(t, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != (record{1, `{"a":1}`}) || got[1] != (record{2, `{"b":2}`}) {
+		t.Fatalf("unexpected records %+v", got)
+	}
+}
+
+func TestRead_BrokenArray(t *testing.T) {
+	got, err := readAll(t, []byte(`[{"a":1}, {broken`))
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("expected error pointing at record 2, got %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("records before the error must be read, got %+v", got)
+	}
+}
+
+func TestRead_StopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Read(strings.NewReader("{}\n{}\n{}\n"), func(int, []byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected callback error after one record, got %v (%d calls)", err, calls)
+	}
+}
+
+func TestRead_Empty(t *testing.T) {
+	got, err := readAll(t, []byte("\n  \n"))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected no records, got %+v %v", got, err)
+	}
+}
@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"yourmodule/internal/models"
+)
+
+// ErrTooManySubscribers достигнут предел одновременных подписчиков
+var ErrTooManySubscribers = errors.New("too many stream subscribers")
+
+// Event сохранённый заказ для подписчиков
+type Event struct {
+	// Seq возрастающий номер события в пределах процесса
+	Seq   uint64
+	Type  string
+	Order models.StoredOrder
+}
+
+// Filter фильтр подписки; пустое поле — без ограничения
+type Filter struct {
+	DeliveryService string
+	CustomerID      string
+}
+
+func (f Filter) match(o models.Order) bool {
+	if f.DeliveryService != "" && f.DeliveryService != o.DeliveryService {
+		return false
+	}
+	if f.CustomerID != "" && f.CustomerID != o.CustomerID {
+		return false
+	}
+	return true
+}
+
+// Subscription подписка на поток заказов
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	ch     chan Event
+	once   sync.Once
+}
+
+// Events канал событий. Закрывается при Close или если подписчик
+// не успевает читать: клиент переподключится и продолжит с новых событий.
+func (s *Subscription) Events() <-chan Event { return s.ch }
+
+// Close отписывает от потока
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.hub.subs, s)
+		close(s.ch)
+	})
+}
+
+// Hub раздаёт сохранённые consumer'ом заказы подписчикам
+type Hub struct {
+	buffer int
+	max    int
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	seq  uint64
+}
+
+// NewHub создаёт Hub; buffer — очередь на подписчика, max — предел подписчиков (0 — без предела)
+func NewHub(buffer, max int) *Hub {
+	return &Hub{buffer: buffer, max: max, subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe подписывает на заказы, подходящие под фильтр
+func (h *Hub) Subscribe(f Filter) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.max > 0 && len(h.subs) >= h.max {
+		return nil, ErrTooManySubscribers
+	}
+	s := &Subscription{hub: h, filter: f, ch: make(chan Event, h.buffer)}
+	h.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Subscribers число активных подписчиков
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Notify рассылает заказ подписчикам. Никогда не блокирует consumer:
+// медленный подписчик отключается.
+func (h *Hub) Notify(ctx context.Context, eventType string, so models.StoredOrder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	h.seq++
+	ev := Event{Seq: h.seq, Type: eventType, Order: so}
+	for s := range h.subs {
+		if !s.filter.match(so.Order) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			slog.WarnContext(ctx, "stream subscriber too slow, disconnecting")
+			s.closeLocked()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"yourmodule/internal/models"
+)
+
+func order(uid, service, customer string) models.StoredOrder {
+	return models.StoredOrder{Order: models.Order{OrderUID: uid, DeliveryService: service, CustomerID: customer}}
+}
+
+func TestHub_Filters(t *testing.T) {
+	h := NewHub(8, 0)
+	all, _ := h.Subscribe(Filter{})
+	meest, _ := h.Subscribe(Filter{DeliveryService: "meest"})
+	cust, _ := h.Subscribe(Filter{DeliveryService: "meest", CustomerID: "c1"})
+
+	ctx := context.Background()
+	h.Notify(ctx, models.EventOrderCreated, order("1", "meest", "c1"))
+	h.Notify(ctx, models.EventOrderCreated, order("2", "meest", "c2"))
+	h.Notify(ctx, models.EventOrderUpdated, order("3", "cdek", "c1"))
+
+	for name, tt := range map[string]struct {
+		sub  *Subscription
+		want int
+	}{"all": {all, 3}, "meest": {meest, 2}, "customer": {cust, 1}} {
+		if got := len(tt.sub.Events()); got != tt.want {
+			t.Fatalf("%s: expected %d events, got %d", name, tt.want, got)
+		}
+	}
+
+	ev := <-all.Events()
+	if ev.Seq != 1 || ev.Type != models.EventOrderCreated || ev.Order.Order.OrderUID != "1" {
+		t.Fatalf("unexpected first event: %+v", ev)
+	}
+}
+
+func TestHub_SlowSubscriberDisconnected(t *testing.T) {
+	h := NewHub(1, 0)
+	slow, _ := h.Subscribe(Filter{})
+
+	h.Notify(context.Background(), models.EventOrderCreated, order("1", "", ""))
+	h.Notify(context.Background(), models.EventOrderCreated, order("2", "", ""))
+
+	if h.Subscribers() != 0 {
+		t.Fatalf("slow subscriber must be removed")
+	}
+	<-slow.Events()
+	if _, ok := <-slow.Events(); ok {
+		t.Fatalf("channel of dropped subscriber must be closed")
+	}
+	// повторный Close после отключения безопасен
+	slow.Close()
+}
+
+func TestHub_MaxSubscribers(t *testing.T) {
+	h := NewHub(1, 1)
+	s, err := h.Subscribe(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(Filter{}); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("expected ErrTooManySubscribers, got %v", err)
+	}
+	s.Close()
+	if _, err := h.Subscribe(Filter{}); err != nil {
+		t.Fatalf("slot must be freed after Close: %v", err)
+	}
+}
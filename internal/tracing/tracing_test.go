@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestInit_None(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{ServiceName: "test", Exporter: ExporterNone})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	// propagator ставится даже без экспортёра
+	fields := otel.GetTextMapPropagator().Fields()
+	var hasTraceparent bool
+	for _, f := range fields {
+		if f == "traceparent" {
+			hasTraceparent = true
+		}
+	}
+	if !hasTraceparent {
+		t.Fatalf("expected W3C propagator, got fields %v", fields)
+	}
+}
+
+func TestInit_Stdout(t *testing.T) {
+	shutdown, err := Init(context.Background(), Config{ServiceName: "test", Exporter: ExporterStdout})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	if _, err := Init(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatalf("expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,352 @@
+// Package warmup прогревает кэш заказов при старте по выбранной стратегии
+package warmup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"yourmodule/internal/cache"
+	"yourmodule/internal/models"
+	"yourmodule/internal/records"
+)
+
+// Strategy какие заказы загружать в кэш
+type Strategy string
+
+const (
+	// StrategyRecent последние записанные в БД заказы
+	StrategyRecent Strategy = "recent"
+	// StrategyPopular самые запрашиваемые заказы по счётчикам прошлого запуска
+	StrategyPopular Strategy = "popular"
+	// StrategyWindow заказы, созданные за последнее окно времени
+	StrategyWindow Strategy = "window"
+	// StrategyExport заказы, перечисленные в файле выгрузки (export), в их версии из БД
+	StrategyExport Strategy = "export"
+)
+
+// getBatch сколько заказов popular достаёт из БД одним запросом
+const getBatch = 500
+
+// progressInterval как часто писать в лог прогресс
+const progressInterval = 5 * time.Second
+
+// stats метрики прогрева в /debug/vars
+var stats = expvar.NewMap("warmup")
+
+// Store источник заказов для прогрева
+type Store interface {
+	StreamOrders(ctx context.Context, f models.OrderFilter, fn func(models.StoredOrder, error) error) error
+	GetOrders(ctx context.Context, orderUIDs []string) (orders map[string]models.StoredOrder, corrupt []string, err error)
+}
+
+// Cache куда загружаются заказы
+type Cache interface {
+	Set(key string, value models.StoredOrder, ttl time.Duration)
+}
+
+type Config struct {
+	Strategy Strategy
+	// Limit сколько заказов загрузить, 0 — без предела
+	Limit int
+	// Window глубина окна для StrategyWindow
+	Window time.Duration
+	// AccessFile счётчики обращений для StrategyPopular, см. SaveAccess
+	AccessFile string
+	// ExportFile выгрузка заказов для StrategyExport
+	ExportFile string
+	// Timeout на весь прогрев, включая повторы
+	Timeout time.Duration
+}
+
+// Warmer прогревает кэш один раз и сообщает о готовности
+type Warmer struct {
+	store Store
+	cache Cache
+	cfg   Config
+
+	loaded  atomic.Int64
+	corrupt atomic.Int64
+	done    chan struct{}
+	now     func() time.Time
+}
+
+func New(store Store, c Cache, cfg Config) *Warmer {
+	if cfg.Strategy == "" {
+		cfg.Strategy = StrategyRecent
+	}
+	return &Warmer{store: store, cache: c, cfg: cfg, done: make(chan struct{}), now: time.Now}
+}
+
+// Ready nil, когда прогрев закончен. Неудачный или прерванный по таймауту прогрев
+// тоже закончен: лучше отдавать заказы из БД, чем не принимать трафик вовсе.
+func (w *Warmer) Ready() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+		return fmt.Errorf("cache warmup in progress: %d orders loaded", w.loaded.Load())
+	}
+}
+
+// Done закрывается по окончании прогрева
+func (w *Warmer) Done() <-chan struct{} { return w.done }
+
+// Run прогревает кэш, повторяя попытку при ошибках БД, пока не выйдет Timeout
+func (w *Warmer) Run(ctx context.Context) {
+	defer close(w.done)
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	start := w.now()
+	stats.Set("strategy", strVar(string(w.cfg.Strategy)))
+	stats.Set("state", strVar("running"))
+	stats.Set("loaded", intVar(&w.loaded))
+	stats.Set("corrupt", intVar(&w.corrupt))
+	slog.InfoContext(ctx, "starting cache warmup", "strategy", w.cfg.Strategy, "limit", w.cfg.Limit)
+
+	stopProgress := w.logProgress(ctx)
+	err := w.runWithRetry(ctx)
+	stopProgress()
+
+	elapsed := w.now().Sub(start)
+	stats.Set("duration_ms", intVal(elapsed.Milliseconds()))
+	if err != nil {
+		stats.Set("state", strVar("failed"))
+		slog.WarnContext(ctx, "cache warmup stopped", "orders", w.loaded.Load(), "duration", elapsed, "err", err)
+		return
+	}
+	stats.Set("state", strVar("done"))
+	slog.InfoContext(ctx, "cache warmed", "strategy", w.cfg.Strategy, "orders", w.loaded.Load(),
+		"corrupt", w.corrupt.Load(), "duration", elapsed)
+}
+
+func (w *Warmer) runWithRetry(ctx context.Context) error {
+	backoff := 300 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := w.warm(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "cache warmup failed", "attempt", attempt, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff < 3*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (w *Warmer) warm(ctx context.Context) error {
+	switch w.cfg.Strategy {
+	case StrategyPopular:
+		uids, err := loadAccess(w.cfg.AccessFile, w.cfg.Limit)
+		if err != nil {
+			slog.WarnContext(ctx, "no access stats, warming recent orders instead", "file", w.cfg.AccessFile, "err", err)
+			return w.stream(ctx, models.OrderFilter{Sort: models.SortRecentlySaved, Limit: w.cfg.Limit})
+		}
+		return w.byUID(ctx, uids)
+	case StrategyWindow:
+		return w.stream(ctx, models.OrderFilter{
+			From:  w.now().Add(-w.cfg.Window),
+			Sort:  models.SortRecentlySaved,
+			Limit: w.cfg.Limit,
+		})
+	case StrategyExport:
+		err := w.export(ctx)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.WarnContext(ctx, "no warmup export file, warming recent orders instead", "file", w.cfg.ExportFile)
+			return w.stream(ctx, models.OrderFilter{Sort: models.SortRecentlySaved, Limit: w.cfg.Limit})
+		}
+		return err
+	default:
+		return w.stream(ctx, models.OrderFilter{Sort: models.SortRecentlySaved, Limit: w.cfg.Limit})
+	}
+}
+
+func (w *Warmer) stream(ctx context.Context, f models.OrderFilter) error {
+	// повторная попытка начинает заново, счётчик тоже
+	w.loaded.Store(0)
+	w.corrupt.Store(0)
+	return w.store.StreamOrders(ctx, f, func(so models.StoredOrder, rowErr error) error {
+		if rowErr != nil {
+			w.corrupt.Add(1)
+			slog.WarnContext(ctx, "corrupt order skipped in warmup", "order_uid", so.Order.OrderUID, "err", rowErr)
+			return nil
+		}
+		w.put(so)
+		return nil
+	})
+}
+
+// byUID загружает заказы из БД пачками в порядке uids
+func (w *Warmer) byUID(ctx context.Context, uids []string) error {
+	w.loaded.Store(0)
+	w.corrupt.Store(0)
+	for len(uids) > 0 {
+		n := min(getBatch, len(uids))
+		batch := uids[:n]
+		orders, corrupt, err := w.store.GetOrders(ctx, batch)
+		if err != nil {
+			return err
+		}
+		w.corrupt.Add(int64(len(corrupt)))
+		// удалённые с тех пор заказы просто пропускаются
+		for _, uid := range batch {
+			if so, ok := orders[uid]; ok {
+				w.put(so)
+			}
+		}
+		uids = uids[n:]
+	}
+	return nil
+}
+
+// errLimit останавливает чтение выгрузки по достижении Limit
+var errLimit = errors.New("warmup limit reached")
+
+// export загружает из БД заказы, перечисленные в файле выгрузки
+// (order-service export -format ndjson). Сами заказы из файла в кэш не кладутся:
+// после выгрузки их могли изменить, а created_at, версии заказа в кэше, в файле нет.
+func (w *Warmer) export(ctx context.Context) error {
+	uids, bad, err := exportUIDs(ctx, w.cfg.ExportFile, w.cfg.Limit)
+	if err != nil {
+		return err
+	}
+	if err := w.byUID(ctx, uids); err != nil {
+		return err
+	}
+	w.corrupt.Add(int64(bad))
+	return nil
+}
+
+// exportUIDs order_uid заказов из файла выгрузки, не больше limit; bad — сколько
+// записей не разобралось
+func exportUIDs(ctx context.Context, path string, limit int) (uids []string, bad int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	err = records.Read(f, func(n int, raw []byte) error {
+		var rec struct {
+			OrderUID string `json:"order_uid"`
+		}
+		if err := json.Unmarshal(raw, &rec); err != nil || rec.OrderUID == "" {
+			bad++
+			slog.WarnContext(ctx, "bad export record skipped", "file", path, "record", n, "err", err)
+			return nil
+		}
+		uids = append(uids, rec.OrderUID)
+		if limit > 0 && len(uids) >= limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, bad, fmt.Errorf("%s: %w", path, err)
+	}
+	return uids, bad, nil
+}
+
+func (w *Warmer) put(so models.StoredOrder) {
+	w.cache.Set(so.Order.OrderUID, so, 0)
+	w.loaded.Add(1)
+}
+
+// logProgress пишет в лог число загруженных заказов, пока прогрев идёт
+func (w *Warmer) logProgress(ctx context.Context) (stop func()) {
+	quit := make(chan struct{})
+	go func() {
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				slog.InfoContext(ctx, "cache warmup progress", "orders", w.loaded.Load())
+			case <-quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() { close(quit) }
+}
+
+// accessFile счётчики обращений, переживающие перезапуск
+type accessFile struct {
+	SavedAt time.Time              `json:"saved_at"`
+	Keys    []cache.Access[string] `json:"keys"`
+}
+
+// SaveAccess сохраняет счётчики обращений к кэшу для StrategyPopular.
+// Файл пишется через временный и rename, чтобы падение не оставило его обрезанным.
+func SaveAccess(path string, top []cache.Access[string]) error {
+	data, err := json.Marshal(accessFile{SavedAt: time.Now().UTC(), Keys: top})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadAccess uid из файла счётчиков, самые запрашиваемые первыми
+func loadAccess(path string, limit int) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var af accessFile
+	if err := json.Unmarshal(data, &af); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	keys := af.Keys
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	uids := make([]string, len(keys))
+	for i, k := range keys {
+		uids[i] = k.Key
+	}
+	return uids, nil
+}
+
+func strVar(s string) *expvar.String {
+	v := new(expvar.String)
+	v.Set(s)
+	return v
+}
+
+func intVal(n int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(n)
+	return v
+}
+
+// intVar показывает текущее значение счётчика при каждом чтении /debug/vars
+func intVar(n *atomic.Int64) expvar.Func {
+	return func() any { return n.Load() }
+}
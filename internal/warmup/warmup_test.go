@@ -0,0 +1,205 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yourmodule/internal/cache"
+	"yourmodule/internal/models"
+)
+
+type fakeStore struct {
+	orders     []models.StoredOrder
+	failures   int
+	lastFilter models.OrderFilter
+	batchCalls [][]string
+	corrupt    []string
+}
+
+func (f *fakeStore) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(models.StoredOrder, error) error) error {
+	f.lastFilter = filter
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("db down")
+	}
+	if err := fn(models.StoredOrder{Order: models.Order{OrderUID: "broken"}}, errors.New("corrupt payload")); err != nil {
+		return err
+	}
+	for _, so := range f.orders {
+		if err := fn(so, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) GetOrders(ctx context.Context, uids []string) (map[string]models.StoredOrder, []string, error) {
+	f.batchCalls = append(f.batchCalls, uids)
+	out := map[string]models.StoredOrder{}
+	for _, uid := range uids {
+		for _, so := range f.orders {
+			if so.Order.OrderUID == uid {
+				out[uid] = so
+			}
+		}
+	}
+	return out, f.corrupt, nil
+}
+
+type fakeCache struct {
+	mu   sync.Mutex
+	data map[string]models.StoredOrder
+}
+
+func (c *fakeCache) Set(key string, val models.StoredOrder, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		c.data = map[string]models.StoredOrder{}
+	}
+	c.data[key] = val
+}
+
+func stored(uids ...string) []models.StoredOrder {
+	out := make([]models.StoredOrder, len(uids))
+	for i, uid := range uids {
+		out[i] = models.StoredOrder{Order: models.Order{OrderUID: uid}}
+	}
+	return out
+}
+
+func TestWarmer_RecentRetriesAndReady(t *testing.T) {
+	store := &fakeStore{orders: stored("a", "b"), failures: 1}
+	c := &fakeCache{}
+	w := New(store, c, Config{Limit: 10, Timeout: 5 * time.Second})
+
+	if w.Ready() == nil {
+		t.Fatal("must not be ready before warmup")
+	}
+	w.Run(context.Background())
+
+	if err := w.Ready(); err != nil {
+		t.Fatalf("must be ready after warmup: %v", err)
+	}
+	if len(c.data) != 2 || w.corrupt.Load() != 1 {
+		t.Fatalf("unexpected cache %v, corrupt %d", c.data, w.corrupt.Load())
+	}
+	if store.lastFilter.Sort != models.SortRecentlySaved || store.lastFilter.Limit != 10 {
+		t.Fatalf("unexpected filter %+v", store.lastFilter)
+	}
+}
+
+func TestWarmer_Window(t *testing.T) {
+	store := &fakeStore{orders: stored("a")}
+	w := New(store, &fakeCache{}, Config{Strategy: StrategyWindow, Window: 24 * time.Hour, Timeout: time.Second})
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	w.Run(context.Background())
+
+	if !store.lastFilter.From.Equal(now.Add(-24 * time.Hour)) {
+		t.Fatalf("unexpected window start %v", store.lastFilter.From)
+	}
+}
+
+func TestWarmer_Popular(t *testing.T) {
+	c := cache.New[string, models.StoredOrder](time.Minute, 0, cache.WithAccessTracking(100))
+	for uid, hits := range map[string]int{"a": 1, "b": 5, "c": 3, "gone": 4} {
+		for i := 0; i < hits; i++ {
+			c.Get(uid)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "access.json")
+	if err := SaveAccess(path, c.TopAccessed(-1)); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeStore{orders: stored("a", "b", "c")}
+	dst := &fakeCache{}
+	New(store, dst, Config{Strategy: StrategyPopular, AccessFile: path, Limit: 3, Timeout: time.Second}).
+		Run(context.Background())
+
+	// самые запрашиваемые, удалённый заказ пропущен
+	if got := strings.Join(store.batchCalls[0], ","); got != "b,gone,c" {
+		t.Fatalf("unexpected uids requested: %s", got)
+	}
+	if len(dst.data) != 2 || dst.data["b"].Order.OrderUID != "b" || dst.data["c"].Order.OrderUID != "c" {
+		t.Fatalf("unexpected cache %v", dst.data)
+	}
+}
+
+func TestWarmer_PopularRetryResetsCounters(t *testing.T) {
+	store := &fakeStore{orders: stored("a"), corrupt: []string{"bad"}}
+	w := New(store, &fakeCache{}, Config{Strategy: StrategyPopular, Timeout: time.Second})
+	for i := 0; i < 2; i++ {
+		if err := w.byUID(context.Background(), []string{"a", "bad"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// повторная попытка считает заново, а не добавляет к прошлой
+	if w.loaded.Load() != 1 || w.corrupt.Load() != 1 {
+		t.Fatalf("unexpected counters: loaded %d, corrupt %d", w.loaded.Load(), w.corrupt.Load())
+	}
+}
+
+func TestWarmer_PopularFallsBackToRecent(t *testing.T) {
+	store := &fakeStore{orders: stored("a")}
+	dst := &fakeCache{}
+	New(store, dst, Config{Strategy: StrategyPopular, AccessFile: filepath.Join(t.TempDir(), "none.json"), Timeout: time.Second}).
+		Run(context.Background())
+
+	if len(store.batchCalls) != 0 || dst.data["a"].Order.OrderUID != "a" {
+		t.Fatalf("expected recent orders without access stats, cache %v", dst.data)
+	}
+}
+
+func TestWarmer_Export(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.ndjson")
+	data := `{"order_uid":"a","track_number":"exported"}` + "\n" +
+		`{not json}` + "\n" +
+		`{"order_uid":"gone"}` + "\n" +
+		`{"order_uid":"b"}` + "\n" +
+		`{"order_uid":"c"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	orders := stored("a", "b", "c")
+	orders[0].Order.TrackNumber = "changed after export"
+	orders[0].CreatedAt = saved
+	store := &fakeStore{orders: orders}
+	dst := &fakeCache{}
+	w := New(store, dst, Config{Strategy: StrategyExport, ExportFile: path, Limit: 3, Timeout: time.Second})
+	w.Run(context.Background())
+
+	if got := strings.Join(store.batchCalls[0], ","); got != "a,gone,b" {
+		t.Fatalf("unexpected uids requested: %s", got)
+	}
+	if len(dst.data) != 2 || w.corrupt.Load() != 1 {
+		t.Fatalf("unexpected cache %v, corrupt %d", dst.data, w.corrupt.Load())
+	}
+	// в кэш попадает версия из БД, а не из файла
+	if so := dst.data["a"]; so.Order.TrackNumber != "changed after export" || !so.CreatedAt.Equal(saved) {
+		t.Fatalf("order must come from the DB, got %+v", so)
+	}
+}
+
+func TestWarmer_TimeoutStillReady(t *testing.T) {
+	store := &fakeStore{failures: 1 << 30}
+	w := New(store, &fakeCache{}, Config{Timeout: 50 * time.Millisecond})
+	w.Run(context.Background())
+
+	// прогрев не удался, но трафик принимать надо
+	if err := w.Ready(); err != nil {
+		t.Fatalf("expected ready after failed warmup, got %v", err)
+	}
+	if got := stats.Get("state").String(); got != `"failed"` {
+		t.Fatalf("unexpected state metric %s", got)
+	}
+}
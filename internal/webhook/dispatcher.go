@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"yourmodule/internal/logging"
+	"yourmodule/internal/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("yourmodule/internal/webhook")
+
+// claimLease на сколько взятая доставка скрыта от других реплик; должен
+// быть больше таймаута HTTP-клиента
+const claimLease = 5 * time.Minute
+
+// Dispatcher рассылает события заказов подписанным webhook'ам.
+//
+// Очередь — таблица журнала доставок: Notify только записывает доставку,
+// а Run забирает из БД доставки, которым пора уйти, в том числе оставшиеся
+// от прошлого запуска или от других реплик. Паузы между попытками — это
+// next_attempt_at в БД, воркеры их не ждут.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	wake   chan struct{}
+
+	workers     int
+	maxAttempts int
+	backoffMin  time.Duration
+	backoffMax  time.Duration
+	refresh     time.Duration
+	poll        time.Duration
+
+	mu       sync.Mutex
+	hooks    []Webhook
+	loadedAt time.Time
+
+	now func() time.Time
+}
+
+// Option настраивает Dispatcher
+type Option func(*Dispatcher)
+
+// WithWorkers число параллельных доставок
+func WithWorkers(n int) Option {
+	return func(d *Dispatcher) { d.workers = n }
+}
+
+// WithRetry число попыток и границы экспоненциального backoff'а между ними
+func WithRetry(maxAttempts int, backoffMin, backoffMax time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoffMin = backoffMin
+		d.backoffMax = backoffMax
+	}
+}
+
+// WithClient HTTP-клиент для запросов к получателям
+func WithClient(c *http.Client) Option {
+	return func(d *Dispatcher) { d.client = c }
+}
+
+// WithRefresh как часто перечитывать список подписок из БД
+func WithRefresh(d time.Duration) Option {
+	return func(disp *Dispatcher) { disp.refresh = d }
+}
+
+// WithPoll как часто искать в БД доставки, которым пора уйти. Новые события
+// этого процесса уходят сразу, интервал важен для повторов и чужих доставок.
+func WithPoll(d time.Duration) Option {
+	return func(disp *Dispatcher) { disp.poll = d }
+}
+
+// NewDispatcher создаёт Dispatcher; доставки начинаются после Run
+func NewDispatcher(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		wake:        make(chan struct{}, 1),
+		workers:     4,
+		maxAttempts: 6,
+		backoffMin:  time.Second,
+		backoffMax:  time.Minute,
+		refresh:     30 * time.Second,
+		poll:        time.Second,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run отправляет доставки из журнала, пока не отменён ctx
+func (d *Dispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.poll)
+	defer t.Stop()
+	for ctx.Err() == nil {
+		// полная пачка — в журнале, скорее всего, есть ещё
+		if d.dispatchDue(ctx) == d.workers {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatchDue отправляет пачку наступивших доставок параллельно; возвращает их число
+func (d *Dispatcher) dispatchDue(ctx context.Context) int {
+	due, err := d.store.ClaimDeliveries(ctx, d.workers, claimLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "claim webhook deliveries failed", "err", err)
+		}
+		return 0
+	}
+	var wg sync.WaitGroup
+	for _, del := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, del)
+		}()
+	}
+	wg.Wait()
+	return len(due)
+}
+
+// Notify записывает в журнал доставки события всем подходящим webhook'ам.
+// Отправка идёт в Run, так что медленные получатели не тормозят consumer.
+func (d *Dispatcher) Notify(ctx context.Context, eventType string, so models.StoredOrder) {
+	hooks, err := d.webhooks(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "load webhooks failed", "err", err)
+		return
+	}
+
+	queued := false
+	for _, h := range hooks {
+		if !h.Matches(eventType, so.Order.DeliveryService) {
+			continue
+		}
+		_, err := d.store.CreateDelivery(ctx, Delivery{
+			WebhookID:     h.ID,
+			EventType:     eventType,
+			OrderUID:      so.Order.OrderUID,
+			OccurredAt:    so.CreatedAt.UTC(),
+			Summary:       Summarize(so.Order),
+			Status:        StatusPending,
+			NextAttemptAt: d.now(),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "create webhook delivery failed", "webhook_id", h.ID, "err", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// webhooks список подписок, перечитывается раз в refresh
+func (d *Dispatcher) webhooks(ctx context.Context) ([]Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hooks != nil && d.now().Sub(d.loadedAt) < d.refresh {
+		return d.hooks, nil
+	}
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hooks == nil {
+		hooks = []Webhook{}
+	}
+	d.hooks, d.loadedAt = hooks, d.now()
+	return hooks, nil
+}
+
+// webhook подписка по id: из кэша списка или, если она новее его, из БД
+func (d *Dispatcher) webhook(ctx context.Context, id int64) (Webhook, error) {
+	hooks, err := d.webhooks(ctx)
+	if err != nil {
+		return Webhook{}, err
+	}
+	for _, h := range hooks {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return d.store.GetWebhook(ctx, id)
+}
+
+// deliver одна попытка доставки; итог и время следующей попытки пишутся в журнал
+func (d *Dispatcher) deliver(ctx context.Context, del Delivery) {
+	ctx = logging.With(ctx, "webhook_id", del.WebhookID, "delivery_id", del.ID)
+
+	hook, err := d.webhook(ctx, del.WebhookID)
+	if errors.Is(err, ErrNotFound) {
+		// подписку удалили, её журнал удалён вместе с ней
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "load webhook failed", "err", err)
+		return
+	}
+
+	body, err := json.Marshal(Event{
+		ID:         del.ID,
+		Type:       del.EventType,
+		OrderUID:   del.OrderUID,
+		OccurredAt: del.OccurredAt,
+		Order:      del.Summary,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "encode webhook event failed", "err", err)
+		return
+	}
+
+	attempt := del.Attempts + 1
+	code, err := d.send(ctx, hook, del, body, attempt)
+	if ctx.Err() != nil {
+		// попытку прервал shutdown: доставку повторят после claimLease
+		return
+	}
+	del.Attempts = attempt
+	del.ResponseCode = code
+	del.Error = ""
+	if err != nil {
+		del.Error = err.Error()
+	}
+
+	switch {
+	case err == nil:
+		del.Status = StatusSucceeded
+	case retryable(code) && attempt < d.maxAttempts:
+		del.Status = StatusPending
+		del.NextAttemptAt = d.now().Add(d.backoff(attempt))
+	default:
+		del.Status = StatusFailed
+		slog.WarnContext(ctx, "webhook delivery failed", "attempts", attempt, "status", code, "err", err)
+	}
+	if uerr := d.store.UpdateDelivery(ctx, del); uerr != nil {
+		slog.ErrorContext(ctx, "update webhook delivery failed", "err", uerr)
+	}
+}
+
+// send одна попытка доставки; возвращает HTTP-код ответа (0 — ответа не было)
+func (d *Dispatcher) send(ctx context.Context, hook Webhook, del Delivery, body []byte, attempt int) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "webhook.deliver",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int64("webhook.id", hook.ID),
+			attribute.Int64("webhook.delivery_id", del.ID),
+			attribute.Int("webhook.attempt", attempt),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks")
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(del.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, d.now(), body))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable повторяем сетевые ошибки, 5xx, 408 и 429; остальные 4xx —
+// ошибка конфигурации получателя, повтор не поможет
+func retryable(code int) bool {
+	return code == 0 || code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// backoff пауза перед попыткой attempt+1: min, 2*min, 4*min ... но не больше max
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := d.backoffMin
+	for i := 1; i < attempt && b < d.backoffMax; i++ {
+		b *= 2
+	}
+	return min(b, d.backoffMax)
+}
@@ -0,0 +1,185 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"yourmodule/internal/models"
+)
+
+// ErrNotFound webhook не найден
+var ErrNotFound = errors.New("webhook not found")
+
+// Webhook подписка партнёра на события заказов.
+// Пустые EventTypes и DeliveryServices означают «все».
+type Webhook struct {
+	ID               int64     `json:"id"`
+	URL              string    `json:"url"`
+	Secret           string    `json:"secret,omitempty"`
+	EventTypes       []string  `json:"event_types"`
+	DeliveryServices []string  `json:"delivery_services"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Validate проверяет URL и типы событий
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	for _, t := range w.EventTypes {
+		if t != models.EventOrderCreated && t != models.EventOrderUpdated {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return nil
+}
+
+// Matches подходит ли событие под фильтры подписки
+func (w Webhook) Matches(eventType, deliveryService string) bool {
+	if len(w.EventTypes) > 0 && !slices.Contains(w.EventTypes, eventType) {
+		return false
+	}
+	if len(w.DeliveryServices) > 0 && !slices.Contains(w.DeliveryServices, deliveryService) {
+		return false
+	}
+	return true
+}
+
+// Status состояние доставки
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Delivery запись журнала доставок: одна на событие и webhook,
+// обновляется после каждой попытки. Журнал же служит очередью: pending-доставки
+// отправляются, когда наступает NextAttemptAt.
+type Delivery struct {
+	ID            int64     `json:"id"`
+	WebhookID     int64     `json:"webhook_id"`
+	EventType     string    `json:"event_type"`
+	OrderUID      string    `json:"order_uid"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Summary       Summary   `json:"summary"`
+	Status        Status    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	ResponseCode  int       `json:"response_code,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DeliveryFilter параметры выборки журнала доставок
+type DeliveryFilter struct {
+	Status Status
+	Limit  int
+}
+
+// Store хранилище подписок и журнала доставок
+type Store interface {
+	CreateWebhook(ctx context.Context, w Webhook) (Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	CreateDelivery(ctx context.Context, d Delivery) (int64, error)
+	// ClaimDeliveries до limit pending-доставок, чей NextAttemptAt наступил.
+	// Взятые доставки откладываются на lease, чтобы их не отправили другие
+	// реплики; если процесс упадёт посреди попытки, доставку повторят после lease.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// UpdateDelivery сохраняет итог попытки и NextAttemptAt следующей
+	UpdateDelivery(ctx context.Context, d Delivery) error
+	ListDeliveries(ctx context.Context, webhookID int64, f DeliveryFilter) ([]Delivery, error)
+}
+
+// Summary сводка заказа для получателя. Персональных данных покупателя и
+// реквизитов оплаты в ней нет: полный заказ партнёр читает через API по order_uid.
+type Summary struct {
+	TrackNumber     string `json:"track_number"`
+	DeliveryService string `json:"delivery_service"`
+	Items           int    `json:"items"`
+	Amount          int    `json:"amount"`
+	Currency        string `json:"currency"`
+}
+
+// Summarize сводка заказа для события
+func Summarize(o models.Order) Summary {
+	return Summary{
+		TrackNumber:     o.TrackNumber,
+		DeliveryService: o.DeliveryService,
+		Items:           len(o.Items),
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+	}
+}
+
+// Event тело запроса к получателю
+type Event struct {
+	// ID совпадает с id доставки: повторные попытки приходят с тем же id
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      Summary   `json:"order"`
+}
+
+// Заголовки запроса к получателю
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Sign подпись тела в формате t=<unix>,v1=<hex hmac-sha256("<t>.<body>")>.
+// Метка времени входит в подпись, чтобы получатель мог отсечь повторы.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+// Verify проверяет подпись; tolerance ограничивает возраст метки времени
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+	ts, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	if tolerance > 0 && now.Sub(time.Unix(ts, 0)).Abs() > tolerance {
+		return errors.New("signature timestamp out of tolerance")
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, t, body)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func mac(secret, t string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
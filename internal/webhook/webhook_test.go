@@ -0,0 +1,370 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"yourmodule/internal/models"
+)
+
+// --------- FAKE STORE ---------
+type fakeStore struct {
+	mu         sync.Mutex
+	hooks      []Webhook
+	deliveries map[int64]Delivery
+	nextID     int64
+	listCalls  int
+}
+
+func newFakeStore(hooks ...Webhook) *fakeStore {
+	return &fakeStore{hooks: hooks, deliveries: map[int64]Delivery{}}
+}
+
+func (f *fakeStore) CreateWebhook(ctx context.Context, w Webhook) (Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.ID = int64(len(f.hooks) + 1)
+	f.hooks = append(f.hooks, w)
+	return w, nil
+}
+
+func (f *fakeStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listCalls++
+	return append([]Webhook(nil), f.hooks...), nil
+}
+
+func (f *fakeStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, h := range f.hooks {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return Webhook{}, ErrNotFound
+}
+
+func (f *fakeStore) DeleteWebhook(ctx context.Context, id int64) error { return nil }
+
+func (f *fakeStore) CreateDelivery(ctx context.Context, d Delivery) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	d.ID = f.nextID
+	f.deliveries[d.ID] = d
+	return d.ID, nil
+}
+
+// ClaimDeliveries как в БД: наступившие pending-доставки по очереди, с арендой
+func (f *fakeStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	var out []Delivery
+	for id := int64(1); id <= f.nextID && len(out) < limit; id++ {
+		d, ok := f.deliveries[id]
+		if !ok || d.Status != StatusPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		d.NextAttemptAt = now.Add(lease)
+		f.deliveries[id] = d
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (f *fakeStore) UpdateDelivery(ctx context.Context, d Delivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deliveries[d.ID] = d
+	return nil
+}
+
+func (f *fakeStore) ListDeliveries(ctx context.Context, webhookID int64, _ DeliveryFilter) ([]Delivery, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) delivery(id int64) Delivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deliveries[id]
+}
+
+func (f *fakeStore) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.deliveries)
+}
+
+// --------- HELPERS ---------
+func storedOrder(uid, service string) models.StoredOrder {
+	return models.StoredOrder{
+		Order: models.Order{
+			OrderUID:        uid,
+			TrackNumber:     "WBILMTESTTRACK",
+			DeliveryService: service,
+			Delivery:        models.Delivery{Name: "Test Testov", Phone: "+9720000000"},
+			Payment:         models.Payment{Transaction: uid, Amount: 1817, Currency: "USD"},
+			Items:           []models.Item{{ChrtID: 9934930}},
+		},
+		Payload:   []byte(`{"order_uid":"` + uid + `","delivery":{"name":"Test Testov"}}`),
+		CreatedAt: time.Date(2026, 1, 16, 15, 58, 0, 0, time.UTC),
+	}
+}
+
+// startDispatcher запускает воркеры и останавливает их в конце теста
+func startDispatcher(t *testing.T, d *Dispatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitStatus ждёт, пока доставка перейдёт в конечный статус
+func waitStatus(t *testing.T, store *fakeStore, id int64) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if d := store.delivery(id); d.Status == StatusSucceeded || d.Status == StatusFailed {
+			return d
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery %d not finished: %+v", id, store.delivery(id))
+	return Delivery{}
+}
+
+// --------- TESTS ---------
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	body := []byte(`{"id":1}`)
+	sig := Sign("s3cret", now, body)
+
+	if err := Verify("s3cret", sig, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := Verify("other", sig, body, now, 5*time.Minute); err == nil {
+		t.Fatalf("signature with wrong secret accepted")
+	}
+	if err := Verify("s3cret", sig, []byte(`{"id":2}`), now, 5*time.Minute); err == nil {
+		t.Fatalf("signature of modified body accepted")
+	}
+	if err := Verify("s3cret", sig, body, now.Add(time.Hour), 5*time.Minute); err == nil {
+		t.Fatalf("stale signature accepted")
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	all := Webhook{}
+	if !all.Matches(models.EventOrderUpdated, "meest") {
+		t.Fatalf("webhook without filters must match everything")
+	}
+	h := Webhook{EventTypes: []string{models.EventOrderCreated}, DeliveryServices: []string{"meest"}}
+	if !h.Matches(models.EventOrderCreated, "meest") {
+		t.Fatalf("expected match")
+	}
+	if h.Matches(models.EventOrderUpdated, "meest") || h.Matches(models.EventOrderCreated, "cdek") {
+		t.Fatalf("filters must be applied")
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	if err := (Webhook{URL: "https://partner.example/hook"}).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, w := range []Webhook{
+		{URL: "partner.example/hook"},
+		{URL: "ftp://partner.example/hook"},
+		{URL: "https://partner.example/hook", EventTypes: []string{"order.deleted"}},
+	} {
+		if err := w.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", w)
+		}
+	}
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(Webhook{ID: 1, URL: receiver.URL, Secret: "s3cret"})
+	d := NewDispatcher(store)
+	startDispatcher(t, d)
+
+	d.Notify(context.Background(), models.EventOrderCreated, storedOrder("a", "meest"))
+
+	var r received
+	select {
+	case r = <-got:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("webhook not delivered")
+	}
+	if err := Verify("s3cret", r.header.Get(HeaderSignature), r.body, time.Now(), time.Minute); err != nil {
+		t.Fatalf("invalid signature: %v", err)
+	}
+	if r.header.Get(HeaderEvent) != models.EventOrderCreated || r.header.Get(HeaderDelivery) != "1" {
+		t.Fatalf("unexpected headers: %v", r.header)
+	}
+	var ev Event
+	if err := json.Unmarshal(r.body, &ev); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	want := Summary{TrackNumber: "WBILMTESTTRACK", DeliveryService: "meest", Items: 1, Amount: 1817, Currency: "USD"}
+	if ev.ID != 1 || ev.OrderUID != "a" || ev.Order != want {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	// персональные данные партнёру не уходят
+	if bytes.Contains(r.body, []byte("Testov")) || bytes.Contains(r.body, []byte("+972")) {
+		t.Fatalf("PII in webhook body: %s", r.body)
+	}
+
+	del := waitStatus(t, store, 1)
+	if del.Status != StatusSucceeded || del.Attempts != 1 || del.ResponseCode != http.StatusOK {
+		t.Fatalf("unexpected delivery log: %+v", del)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(Webhook{ID: 1, URL: receiver.URL, Secret: "x"})
+	d := NewDispatcher(store, WithRetry(5, time.Millisecond, 4*time.Millisecond), WithPoll(5*time.Millisecond))
+	startDispatcher(t, d)
+
+	d.Notify(context.Background(), models.EventOrderUpdated, storedOrder("a", "meest"))
+
+	del := waitStatus(t, store, 1)
+	if del.Status != StatusSucceeded || del.Attempts != 3 || del.Error != "" {
+		t.Fatalf("expected success on third attempt, got %+v", del)
+	}
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(Webhook{ID: 1, URL: receiver.URL, Secret: "x"})
+	d := NewDispatcher(store, WithRetry(5, time.Millisecond, time.Millisecond))
+	startDispatcher(t, d)
+
+	d.Notify(context.Background(), models.EventOrderCreated, storedOrder("a", "meest"))
+
+	// 410 не повторяем: это не временная ошибка
+	del := waitStatus(t, store, 1)
+	if del.Status != StatusFailed || del.Attempts != 1 || del.ResponseCode != http.StatusGone || calls.Load() != 1 {
+		t.Fatalf("expected single failed attempt, got %+v (calls %d)", del, calls.Load())
+	}
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	store := newFakeStore(
+		Webhook{ID: 1, URL: "http://127.0.0.1:1/a", EventTypes: []string{models.EventOrderCreated}},
+		Webhook{ID: 2, URL: "http://127.0.0.1:1/b", DeliveryServices: []string{"cdek"}},
+	)
+	d := NewDispatcher(store)
+
+	// без Run доставки только записываются в журнал
+	d.Notify(context.Background(), models.EventOrderUpdated, storedOrder("a", "meest"))
+	if n := store.count(); n != 0 {
+		t.Fatalf("no webhook matches, got %d deliveries", n)
+	}
+	d.Notify(context.Background(), models.EventOrderCreated, storedOrder("a", "cdek"))
+	if n := store.count(); n != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", n)
+	}
+	if store.listCalls != 1 {
+		t.Fatalf("webhooks must be cached between events, loaded %d times", store.listCalls)
+	}
+}
+
+func TestDispatcher_NotifyDoesNotBlock(t *testing.T) {
+	store := newFakeStore(Webhook{ID: 1, URL: "http://127.0.0.1:1/a"})
+	d := NewDispatcher(store, WithWorkers(1))
+
+	// Run не запущен: раньше Notify вставал на заполненной очереди
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			d.Notify(context.Background(), models.EventOrderCreated, storedOrder("a", "meest"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Notify blocked without running dispatcher")
+	}
+	if n := store.count(); n != 100 {
+		t.Fatalf("expected 100 pending deliveries, got %d", n)
+	}
+}
+
+func TestDispatcher_ResumesPendingAfterRestart(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	// доставка прошлого запуска: одна попытка была, повтор уже наступил
+	store := newFakeStore(Webhook{ID: 1, URL: receiver.URL, Secret: "x"})
+	id, _ := store.CreateDelivery(context.Background(), Delivery{
+		WebhookID:     1,
+		EventType:     models.EventOrderCreated,
+		OrderUID:      "a",
+		Status:        StatusPending,
+		Attempts:      1,
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+
+	startDispatcher(t, NewDispatcher(store, WithPoll(10*time.Millisecond)))
+
+	del := waitStatus(t, store, id)
+	if del.Status != StatusSucceeded || del.Attempts != 2 || calls.Load() != 1 {
+		t.Fatalf("expected pending delivery to be resumed, got %+v (calls %d)", del, calls.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(newFakeStore(), WithRetry(10, time.Second, 5*time.Second))
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Fatalf("attempt %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+}
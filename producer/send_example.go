@@ -9,9 +9,14 @@ import (
 	"strconv"
 	"time"
 	"yourmodule/internal/models"
+	"yourmodule/internal/tracing"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // envOr возвращает значение переменной окружения или дефолт
@@ -48,6 +53,18 @@ func main() {
 	count := envInt("COUNT", 10)
 	invalidRate := envFloat("INVALID_RATE", 0.2) // 20% сообщений будут "сломаны"
 
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		ServiceName: "order-producer",
+		Exporter:    envOr("TRACING_EXPORTER", tracing.ExporterNone),
+		Endpoint:    os.Getenv("TRACING_ENDPOINT"),
+		Insecure:    true,
+	})
+	if err != nil {
+		log.Fatalf("tracing init: %v", err)
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+	tracer := otel.Tracer("yourmodule/producer")
+
 	w := kafka.NewWriter(kafka.WriterConfig{
 		Brokers:  []string{broker},
 		Topic:    topic,
@@ -72,10 +89,25 @@ func main() {
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, span := tracer.Start(ctx, "producer.send",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(attribute.String("order.uid", uid)),
+		)
+
+		// контекст трейса уходит в заголовки сообщения
+		carrier := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+		headers := make([]kafka.Header, 0, len(carrier))
+		for k, v := range carrier {
+			headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+
 		err = w.WriteMessages(ctx, kafka.Message{
-			Key:   []byte(uid),
-			Value: data,
+			Key:     []byte(uid),
+			Value:   data,
+			Headers: headers,
 		})
+		span.End()
 		cancel()
 
 		if err != nil {